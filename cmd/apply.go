@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+)
+
+func init() {
+	applyCmd.Flags().BoolVar(&noUndo, "no-undo", false,
+		"leave the changes made by a failed migration in place")
+
+	rootCmd.AddCommand(applyCmd)
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply PLAN_FILE",
+	Short: "Apply a plan saved by plan --out",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := ioutil.ReadFile(args[0])
+
+		if err != nil {
+			return fmt.Errorf("couldn't read plan: %w", err)
+		}
+
+		var saved plan.SavedPlan
+
+		if err = json.Unmarshal(b, &saved); err != nil {
+			return fmt.Errorf("couldn't read plan %v: %w", args[0], err)
+		}
+
+		if err = validateEnv(saved.EnvName); err != nil {
+			return fmt.Errorf("couldn't read plan %v: invalid environment: %w", args[0], err)
+		}
+
+		ctx := newContext(saved.EnvName)
+
+		if address := ctx.Conf.Server.Address; address != saved.Server {
+			return fmt.Errorf("plan was made against %v, not %v", saved.Server, address)
+		}
+
+		interrupt, stop := interruptible()
+		defer stop()
+
+		getInterruptibleLock(ctx, saved.EnvName, interrupt)
+		defer releaseLock(ctx, saved.EnvName)
+
+		interrupt = untilLockLost(interrupt, ctx.Lock)
+
+		if err = saved.Snapshot.Verify(ctx.Es, ctx.Changelog, saved.EnvName); err != nil {
+			return fmt.Errorf("refusing to apply plan: %w", err)
+		}
+
+		println(fmt.Sprintf("Applying plan for %v, version %v", saved.EnvName, saved.Version))
+
+		logPlan(saved.Actions, ctx.Conf.Server)
+
+		if err = executePlan(ctx, saved.Actions, interrupt); err != nil {
+			return err
+		}
+
+		println("Complete")
+		return nil
+	},
+}
@@ -9,17 +9,7 @@ import (
 )
 
 func newContext(envName string) *context.Context {
-	conf, err := config.NewConfig()
-
-	if err != nil {
-		fatalError("couldn't read configuration: %v", err)
-	}
-
-	esClient, err := es.NewClient(conf.Server)
-
-	if err != nil {
-		fatalError("couldn't create elasticsearch client: %v", err)
-	}
+	conf, esClient := newClient()
 
 	resSchema, err := schema.GetSchema(conf, envName)
 
@@ -29,6 +19,8 @@ func newContext(envName string) *context.Context {
 
 	changelog := resource.NewChangelog(conf.Changelog, esClient)
 	lock := resource.NewLock(conf.Changelog, esClient)
+	lock.SetGlobal(conf.Changelog.GlobalLock)
+	journal := resource.NewJournal(conf.Changelog, esClient)
 	proc := resource.NewPreprocessor(conf.Preprocess)
 
 	return &context.Context{
@@ -37,6 +29,25 @@ func newContext(envName string) *context.Context {
 		Es:        esClient,
 		Changelog: changelog,
 		Lock:      lock,
+		Journal:   journal,
 		Proc:      proc,
 	}
 }
+
+// newClient returns the configuration and a client for the configured server, for commands which don't need the
+// schema of an environment
+func newClient() (config.Config, *es.Client) {
+	conf, err := config.NewConfig()
+
+	if err != nil {
+		fatalError("couldn't read configuration: %v", err)
+	}
+
+	esClient, err := es.NewClient(conf.Server)
+
+	if err != nil {
+		fatalError("couldn't create elasticsearch client: %v", err)
+	}
+
+	return conf, esClient
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/config"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var checkDrift bool
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift ENVIRONMENT",
+	Short: "Show changes made to esup-managed resources outside of esup",
+	Long: fmt.Sprintf("Show changes made to esup-managed resources outside of esup.\n\n"+
+		"Exits with status %v if there is drift.", changesPendingExitCode),
+	Args: validateEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		ctx := newContext(envName)
+
+		planner := plan.NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, ctx.Schema, ctx.Proc, "")
+
+		drift, err := planner.Drift()
+
+		if err != nil {
+			return fmt.Errorf("couldn't detect drift: %w", err)
+		}
+
+		logDrift(drift, ctx.Conf.Server)
+
+		if len(drift) > 0 {
+			os.Exit(changesPendingExitCode)
+		}
+
+		return nil
+	},
+}
+
+func logDrift(drift []plan.Drift, serverConfig config.ServerConfig) {
+	if len(drift) == 0 {
+		println("No drift")
+		return
+	}
+
+	println(fmt.Sprintf("Drift on %s:\n", serverConfig.Address))
+
+	msg := ""
+
+	for _, d := range drift {
+		msg += fmt.Sprintf(" - %v\n", d)
+		msg += describeChanges("changes", d.Changes)
+	}
+
+	print(msg)
+}
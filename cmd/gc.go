@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	stdcontext "context"
+	"fmt"
+	"github.com/hdpe.me/esup/context"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/hdpe.me/esup/resource"
+	"github.com/hdpe.me/esup/util"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	gcCmd.Flags().BoolVarP(&approve, "approve", "a", false,
+		"approve deleting orphaned indices without prompting")
+
+	rootCmd.AddCommand(gcCmd)
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc [ENVIRONMENT]",
+	Short: "Delete index set indices which no alias or changelog entry refers to",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return nil
+		}
+		return validateEnv(args[0])
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var ctx *context.Context
+		envName := ""
+		indexSets := make([]string, 0)
+
+		if len(args) > 0 {
+			envName = args[0]
+			ctx = newContext(envName)
+
+			// index sets in the schema may not be in the changelog yet, if their first migration failed
+			for _, is := range ctx.Schema.IndexSets {
+				if is.Meta.Index == "" {
+					indexSets = append(indexSets, is.IndexSet)
+				}
+			}
+		} else {
+			conf, esClient := newClient()
+
+			// a migration in progress has an index that's not yet aliased or in the changelog, so without an
+			// environment every environment is locked
+			lock := resource.NewLock(conf.Changelog, esClient)
+			lock.SetGlobal(true)
+
+			ctx = &context.Context{
+				Conf:      conf,
+				Es:        esClient,
+				Changelog: resource.NewChangelog(conf.Changelog, esClient),
+				Lock:      lock,
+			}
+		}
+
+		getLock(ctx, envName)
+		defer releaseLock(ctx, envName)
+
+		orphans, err := plan.Orphans(ctx.Es, ctx.Changelog, envName, indexSets)
+
+		if err != nil {
+			return fmt.Errorf("couldn't find orphaned indices: %w", err)
+		}
+
+		if len(orphans) == 0 {
+			println("No orphaned indices")
+			return nil
+		}
+
+		println(fmt.Sprintf("Orphaned indices on %s:\n", ctx.Conf.Server.Address))
+
+		now := (&util.DefaultClock{}).Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		_, _ = fmt.Fprintln(w, "INDEX\tSTATUS\tDOCS\tSIZE\tAGE")
+
+		for _, index := range orphans {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", index.Name, index.Status, index.DocsCount,
+				formatBytes(index.StoreSize), formatAge(now.Sub(index.CreationDate)))
+		}
+
+		if err = w.Flush(); err != nil {
+			return err
+		}
+
+		if !approve && !confirm(stdcontext.Background()) {
+			println("Cancelled")
+			return nil
+		}
+
+		for _, index := range orphans {
+			if err = ctx.Es.DeleteIndex(index.Name); err != nil {
+				return err
+			}
+
+			println(fmt.Sprintf("Deleted %v", index.Name))
+		}
+
+		println("Complete")
+		return nil
+	},
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%vd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%vh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%vm", int(d.Minutes()))
+	}
+}
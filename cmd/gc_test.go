@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_formatAge(t *testing.T) {
+	testCases := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "0m"},
+		{in: 59 * time.Minute, want: "59m"},
+		{in: 90 * time.Minute, want: "1h"},
+		{in: 47 * time.Hour, want: "1d"},
+		{in: 30 * 24 * time.Hour, want: "30d"},
+	}
+
+	for _, tc := range testCases {
+		if got := formatAge(tc.in); got != tc.want {
+			t.Errorf("formatAge(%v): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
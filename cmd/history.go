@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+var showRevision int
+
+func init() {
+	historyCmd.Flags().IntVar(&showRevision, "show", 0, "print the content and meta of revision N")
+	rootCmd.AddCommand(historyCmd)
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history ENVIRONMENT RESOURCE_TYPE RESOURCE_IDENTIFIER",
+	Short: "List the changelog entries for a resource",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(3)(cmd, args); err != nil {
+			return err
+		}
+		if err := validateEnv(args[0]); err != nil {
+			return err
+		}
+		// pipelines only have tombstone entries, written when they're pruned
+		if args[1] == "pipeline" {
+			return nil
+		}
+		return validateResourceType(args[1])
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		resourceType := args[1]
+		resourceIdentifier := args[2]
+
+		ctx := newContext(envName)
+
+		entries, err := ctx.Changelog.GetChangelogEntries(resourceType, resourceIdentifier, envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog history: %w", err)
+		}
+
+		if len(entries) == 0 {
+			return fmt.Errorf("no changelog entries for %v %v in %v", resourceType, resourceIdentifier, envName)
+		}
+
+		if showRevision != 0 {
+			if showRevision < 1 || showRevision > len(entries) {
+				return fmt.Errorf("no revision %v of %v %v: wanted 1 to %v", showRevision, resourceType,
+					resourceIdentifier, len(entries))
+			}
+
+			entry := entries[showRevision-1]
+
+			fmt.Printf("Revision:   %v\n", showRevision)
+			fmt.Printf("Timestamp:  %v\n", entry.Timestamp)
+			fmt.Printf("Final name: %v\n", orDash(entry.FinalName))
+
+			if entry.Deleted {
+				fmt.Println("Deleted:    true")
+			}
+
+			fmt.Printf("\nContent:\n%v\n", orDash(entry.Content))
+			fmt.Printf("\nMeta:\n%v\n", orDash(entry.Meta))
+
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		_, _ = fmt.Fprintln(w, "REVISION\tTIMESTAMP\tFINAL NAME")
+
+		for i, entry := range entries {
+			finalName := orDash(entry.FinalName)
+
+			if entry.Deleted {
+				finalName = "(deleted)"
+			}
+
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\n", i+1, entry.Timestamp, finalName)
+		}
+
+		return w.Flush()
+	},
+}
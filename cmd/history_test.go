@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_validateHistoryArgs(t *testing.T) {
+	testCases := []struct {
+		in        []string
+		wantValid bool
+	}{
+		{in: []string{}, wantValid: false},
+		{in: []string{"x"}, wantValid: false},
+		{in: []string{"x", "index_set"}, wantValid: false},
+		{in: []string{"", "index_set", "i"}, wantValid: false},
+		{in: []string{"-x", "index_set", "i"}, wantValid: false},
+		{in: []string{"x", "index_set", "i", "y"}, wantValid: false},
+		{in: []string{"x", "other", "i"}, wantValid: false},
+		{in: []string{"x", "index_set", "i"}, wantValid: true},
+		{in: []string{"x-y.z", "document", "i/d"}, wantValid: true},
+		{in: []string{"x", "pipeline", "p"}, wantValid: true},
+	}
+
+	for _, tc := range testCases {
+		err := historyCmd.Args(nil, tc.in)
+		if valid := err == nil; valid != tc.wantValid {
+			t.Errorf("%q valid? got %v, want %v", tc.in, valid, tc.wantValid)
+		}
+	}
+}
@@ -7,6 +7,9 @@ import (
 )
 
 func init() {
+	importCmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 0,
+		"how long to wait for another process to release the lock, e.g. 10m - by default fails straight away")
+
 	rootCmd.AddCommand(importCmd)
 }
 
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/resource"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+var force bool
+var global bool
+
+func init() {
+	lockReleaseCmd.Flags().BoolVar(&force, "force", false,
+		"release the lock even if another process holds it and its lease hasn't expired")
+
+	lockReleaseCmd.Flags().BoolVar(&global, "global", false,
+		"release the global lock rather than an environment's lock")
+
+	lockCmd.AddCommand(lockStatusCmd)
+	lockCmd.AddCommand(lockReleaseCmd)
+	rootCmd.AddCommand(lockCmd)
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Show or release changelog locks",
+}
+
+var lockStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show who holds each changelog lock",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := newLock().Status()
+
+		if err != nil {
+			return fmt.Errorf("couldn't get locks: %w", err)
+		}
+
+		now := time.Now()
+		locked := false
+
+		for _, entry := range entries {
+			if entry.IsLocked() {
+				println(describeLock(entry, now))
+				locked = true
+			}
+		}
+
+		if !locked {
+			println("Not locked")
+		}
+
+		return nil
+	},
+}
+
+var lockReleaseCmd = &cobra.Command{
+	Use:   "release [ENVIRONMENT]",
+	Short: "Release a changelog lock left behind by a process which died",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if global {
+			return cobra.NoArgs(cmd, args)
+		}
+		return validateEnvArgs(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lockId := es.GlobalLockId
+
+		if !global {
+			lockId = args[0]
+		}
+
+		lock := newLock()
+
+		entry, err := lock.Entry(lockId)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get lock: %w", err)
+		}
+
+		if !entry.IsLocked() {
+			println("Not locked")
+			return nil
+		}
+
+		if !force && !entry.IsExpired(time.Now()) {
+			return fmt.Errorf("refusing to release lock held by %v since %v - use --force to release it anyway",
+				entry.Holder(), entry.Timestamp)
+		}
+
+		if entry, err = lock.ForceRelease(lockId); err != nil {
+			return fmt.Errorf("couldn't release lock: %w", err)
+		}
+
+		println(fmt.Sprintf("Released lock held by %v since %v", entry.Holder(), entry.Timestamp))
+		return nil
+	},
+}
+
+func newLock() *resource.Lock {
+	conf, esClient := newClient()
+
+	return resource.NewLock(conf.Changelog, esClient)
+}
+
+func describeLock(entry es.LockEntry, now time.Time) string {
+	if !entry.IsLocked() {
+		return "Not locked"
+	}
+
+	msg := fmt.Sprintf("%v locked by %v", entry.LockId, entry.Holder())
+
+	if entry.IsGlobal() {
+		msg = fmt.Sprintf("All environments locked by %v", entry.Holder())
+
+		if entry.EnvName != "" {
+			msg += fmt.Sprintf(" migrating %v", entry.EnvName)
+		}
+	}
+
+	msg += fmt.Sprintf(" since %v", entry.Timestamp)
+
+	switch {
+	case entry.Expires == "":
+		msg += " - lease never expires"
+	case entry.IsExpired(now):
+		msg += fmt.Sprintf(" - lease expired %v", entry.Expires)
+	default:
+		msg += fmt.Sprintf(" - lease expires %v", entry.Expires)
+	}
+
+	return msg
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/hdpe.me/esup/es"
+	"testing"
+	"time"
+)
+
+func Test_describeLock(t *testing.T) {
+	now := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	holder := es.LockEntry{
+		LockId:    "prod",
+		Status:    es.LockStatusLocked,
+		ClientId:  "esup",
+		EnvName:   "prod",
+		Hostname:  "ci-1",
+		Pid:       42,
+		User:      "deploy",
+		Timestamp: "2001-02-03T04:00:00.000",
+	}
+
+	expired := holder
+	expired.Expires = "2001-02-03T04:02:00.000"
+
+	renewed := holder
+	renewed.Expires = "2001-02-03T04:07:00.000"
+
+	legacy := es.LockEntry{
+		LockId:    es.GlobalLockId,
+		Status:    es.LockStatusLocked,
+		ClientId:  "esup",
+		Timestamp: "2001-02-03T04:00:00.000",
+	}
+
+	testCases := []struct {
+		in   es.LockEntry
+		want string
+	}{
+		{
+			in:   es.LockEntry{Status: es.LockStatusUnlocked},
+			want: "Not locked",
+		},
+		{
+			in: renewed,
+			want: "prod locked by deploy@ci-1 (pid 42) since 2001-02-03T04:00:00.000 - " +
+				"lease expires 2001-02-03T04:07:00.000",
+		},
+		{
+			in: expired,
+			want: "prod locked by deploy@ci-1 (pid 42) since 2001-02-03T04:00:00.000 - " +
+				"lease expired 2001-02-03T04:02:00.000",
+		},
+		{
+			in:   legacy,
+			want: "All environments locked by esup since 2001-02-03T04:00:00.000 - lease never expires",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := describeLock(tc.in, now); got != tc.want {
+			t.Errorf("describeLock(%+v): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
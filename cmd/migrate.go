@@ -2,18 +2,31 @@ package cmd
 
 import (
 	"bufio"
+	stdcontext "context"
+	"errors"
 	"fmt"
 	"github.com/hdpe.me/esup/config"
+	"github.com/hdpe.me/esup/context"
+	"github.com/hdpe.me/esup/diff"
+	"github.com/hdpe.me/esup/es"
 	"github.com/hdpe.me/esup/plan"
+	"github.com/hdpe.me/esup/resource"
 	"github.com/hdpe.me/esup/util"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
 	"regexp"
 	"strings"
+	"syscall"
 )
 
 var approve bool
 var version string
+var output string
+var prune bool
+var noUndo bool
+var resume bool
+var discard bool
 
 func init() {
 	migrateCmd.Flags().BoolVarP(&approve, "approve", "a", false,
@@ -23,53 +36,107 @@ func init() {
 		(&util.DefaultClock{}).Now().UTC().Format("20060102150405"),
 		"index version suffix for this migration - defaults to current timestamp")
 
+	migrateCmd.Flags().StringVar(&output, "output", "text",
+		"format in which to print the plan - text or json")
+
+	migrateCmd.Flags().BoolVar(&checkDrift, "check-drift", false,
+		"check for changes made outside of esup before planning")
+
+	migrateCmd.Flags().BoolVar(&prune, "prune", false,
+		"remove resources which are no longer in the schema")
+
+	migrateCmd.Flags().BoolVar(&noUndo, "no-undo", false,
+		"leave the changes made by a failed migration in place")
+
+	migrateCmd.Flags().BoolVar(&resume, "resume", false,
+		"resume an interrupted migration instead of planning a new one")
+
+	migrateCmd.Flags().BoolVar(&discard, "discard", false,
+		"discard an unfinished migration, leaving its changes in place, and plan a new one")
+
+	migrateCmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 0,
+		"how long to wait for another process to release the lock, e.g. 10m - by default fails straight away")
+
 	rootCmd.AddCommand(migrateCmd)
 }
 
 var migrateCmd = &cobra.Command{
 	Use:   "migrate ENVIRONMENT",
 	Short: "Migrate an esup schema",
-	Args: func(cmd *cobra.Command, args []string) error {
-		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
-			return err
-		}
-		return validateEnv(args[0])
-	},
+	Args:  validateEnvArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		envName := args[0]
 
 		ctx := newContext(envName)
 
 		planner := plan.NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, ctx.Schema, ctx.Proc, version)
+		planner.SetPrune(prune)
 
-		getLock(ctx, envName)
+		// handle signals from the start, so an interrupt while planning or confirming still releases the lock
+		interrupt, stop := interruptible()
+		defer stop()
+
+		getInterruptibleLock(ctx, envName, interrupt)
 		defer releaseLock(ctx, envName)
 
+		interrupt = untilLockLost(interrupt, ctx.Lock)
+
+		executor := newExecutor(ctx)
+		executor.SetContext(interrupt)
+
+		if resume && discard {
+			return fmt.Errorf("--resume and --discard can't be used together")
+		}
+
+		if resume {
+			return resumeRun(ctx, executor, interrupt)
+		}
+
+		if run, ok, err := executor.UnfinishedRun(); err != nil {
+			return err
+		} else if ok && !discard {
+			return fmt.Errorf("refusing to discard unfinished migration started %v - use --resume to continue it, "+
+				"or --discard to plan a new one anyway", run.Started)
+		} else if ok {
+			println(fmt.Sprintf("Discarding unfinished migration started %v, leaving its changes in place\n",
+				run.Started))
+		}
+
+		if checkDrift {
+			drift, err := planner.Drift()
+
+			if err != nil {
+				return fmt.Errorf("couldn't detect drift: %w", err)
+			}
+
+			if len(drift) > 0 {
+				logDrift(drift, ctx.Conf.Server)
+				return fmt.Errorf("refusing to migrate: resources have drifted")
+			}
+		}
+
 		resPlan, err := planner.Plan()
 
 		if err != nil {
 			return fmt.Errorf("couldn't plan update: %w", err)
 		}
 
-		logPlan(resPlan, ctx.Conf.Server)
+		if interrupt.Err() != nil {
+			return errInterrupted
+		}
 
-		if !approve {
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Print("\nConfirm [Y/n]: ")
-			text, _ := reader.ReadString('\n')
+		if err = printPlan(resPlan, ctx.Conf.Server, output); err != nil {
+			return err
+		}
 
-			if strings.ToLower(text) != "y\n" {
-				println("Cancelled")
-				return nil
-			}
+		if !approve && !confirm(interrupt) {
+			return cancelled(interrupt)
 		}
 
-		coll := plan.NewCollector()
+		err = executor.Execute(resPlan)
 
-		for _, item := range resPlan {
-			if err = item.Execute(ctx.Es, ctx.Changelog, coll); err != nil {
-				return fmt.Errorf("couldn't execute %v: %v", item, err)
-			}
+		if err = logExecutionError(err); err != nil {
+			return err
 		}
 
 		println("Complete")
@@ -77,6 +144,198 @@ var migrateCmd = &cobra.Command{
 	},
 }
 
+func resumeRun(ctx *context.Context, executor *plan.Executor, interrupt stdcontext.Context) error {
+	run, ok, err := executor.UnfinishedRun()
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("no unfinished migration to resume in %v", ctx.Schema.EnvName)
+	}
+
+	println(fmt.Sprintf("Resuming migration started %v, with %v of %v actions completed\n", run.Started,
+		run.Completed, len(run.Plan)))
+
+	logPlan(run.Remaining(), ctx.Conf.Server)
+
+	if !approve && !confirm(interrupt) {
+		return cancelled(interrupt)
+	}
+
+	err = executor.Resume(run)
+
+	if err = logExecutionError(err); err != nil {
+		return err
+	}
+
+	println("Complete")
+	return nil
+}
+
+// errInterrupted is returned when esup is interrupted before it has made any changes
+var errInterrupted = errors.New("interrupted before making any changes")
+
+// confirm prompts for confirmation, returning false if it's refused or interrupt is cancelled while waiting
+func confirm(interrupt stdcontext.Context) bool {
+	answer := make(chan string, 1)
+
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		text, _ := reader.ReadString('\n')
+		answer <- text
+	}()
+
+	// prompt on stderr with everything else, so only the plan is written to stdout
+	_, _ = fmt.Fprint(os.Stderr, "\nConfirm [Y/n]: ")
+
+	select {
+	case text := <-answer:
+		return strings.ToLower(text) == "y\n"
+	case <-interrupt.Done():
+		return false
+	}
+}
+
+// cancelled reports a refused confirmation, returning an error if it was refused by interrupting esup
+func cancelled(interrupt stdcontext.Context) error {
+	if interrupt.Err() != nil {
+		return errInterrupted
+	}
+
+	println("Cancelled")
+	return nil
+}
+
+func newExecutor(ctx *context.Context) *plan.Executor {
+	executor := plan.NewExecutor(ctx.Es, ctx.Changelog, ctx.Journal, ctx.Schema.EnvName)
+	executor.SetUndo(!noUndo)
+	executor.SetConcurrency(ctx.Conf.IndexSets.Concurrency)
+	return executor
+}
+
+// executePlan executes a plan, refusing to if that would discard an unfinished run recorded in the journal
+func executePlan(ctx *context.Context, resPlan []plan.PlanAction, interrupt stdcontext.Context) error {
+	if interrupt.Err() != nil {
+		return errInterrupted
+	}
+
+	executor := newExecutor(ctx)
+	executor.SetContext(interrupt)
+
+	if run, ok, err := executor.UnfinishedRun(); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("refusing to discard unfinished migration started %v - run migrate with --resume to "+
+			"continue it first", run.Started)
+	}
+
+	return logExecutionError(executor.Execute(resPlan))
+}
+
+// untilLockLost returns a context cancelled along with interrupt, or when the lock is found to have been taken over,
+// so a run stops rather than carrying on without the lock
+func untilLockLost(interrupt stdcontext.Context, lock *resource.Lock) stdcontext.Context {
+	ctx, cancel := stdcontext.WithCancel(interrupt)
+
+	go func() {
+		select {
+		case <-lock.Lost():
+			println("Lock was taken over by another process; stopping")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx
+}
+
+// interruptible returns a context which a SIGINT or SIGTERM cancels, and a function which stops listening for them.
+// A second signal terminates esup immediately.
+func interruptible() (stdcontext.Context, func()) {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			println(fmt.Sprintf("\nReceived %v; stopping", sig))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// logExecutionError logs the steps taken to undo a failed or interrupted run, and returns the error
+func logExecutionError(err error) error {
+	var execErr *plan.ExecutionError
+
+	if !errors.As(err, &execErr) {
+		return err
+	}
+
+	if execErr.Undo != nil {
+		if execErr.Interrupted {
+			logUndo("interrupted", execErr.Undo)
+		} else {
+			logUndo("failed", execErr.Undo)
+		}
+	}
+
+	if execErr.Interrupted {
+		if execErr.Undo == nil {
+			return fmt.Errorf("migration interrupted during %v - run migrate with --resume to continue it",
+				execErr.Action)
+		}
+		return fmt.Errorf("migration interrupted during %v", execErr.Action)
+	}
+
+	return err
+}
+
+func logUndo(reason string, steps []plan.UndoStep) {
+	if len(steps) == 0 {
+		println(fmt.Sprintf("Migration %v; nothing to undo", reason))
+		return
+	}
+
+	msg := fmt.Sprintf("Migration %v; undoing changes, leaving aliases as they are:\n\n", reason)
+
+	for _, step := range steps {
+		msg += fmt.Sprintf(" - %v\n", step)
+	}
+
+	print(msg)
+}
+
+func printPlan(resPlan []plan.PlanAction, serverConfig config.ServerConfig, format string) error {
+	switch format {
+	case "text":
+		logPlan(resPlan, serverConfig)
+	case "json":
+		b, err := plan.MarshalPlan(resPlan)
+
+		if err != nil {
+			return fmt.Errorf("couldn't marshal plan: %w", err)
+		}
+
+		fmt.Println(string(b))
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+
+	return nil
+}
+
 func logPlan(plan []plan.PlanAction, serverConfig config.ServerConfig) {
 	if len(plan) == 0 {
 		println("No changes")
@@ -86,18 +345,54 @@ func logPlan(plan []plan.PlanAction, serverConfig config.ServerConfig) {
 	println(fmt.Sprintf("Planned changes on %s:\n", serverConfig.Address))
 
 	msg := ""
+	described := make(map[string]bool)
 
 	for _, item := range plan {
 		msg += fmt.Sprintf(" - %v\n", item)
+
+		// describe the changes to each resource under the first action planned for it
+		res := item.Resource()
+		key := fmt.Sprintf("%v:%v", res.Type, res.Identifier)
+
+		if !described[key] {
+			msg += describeChanges("definition", res.DefinitionChanges)
+			msg += describeChanges("meta", res.MetaChanges)
+			described[key] = true
+		}
 	}
 
 	print(msg)
 }
 
+func describeChanges(label string, changes []diff.Change) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	msg := fmt.Sprintf("     %v:\n", label)
+
+	for _, c := range changes {
+		msg += fmt.Sprintf("       %v\n", c)
+	}
+
+	return msg
+}
+
+func validateEnvArgs(cmd *cobra.Command, args []string) error {
+	if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+		return err
+	}
+	return validateEnv(args[0])
+}
+
 func validateEnv(str string) error {
 	p := `^[\pLl\pN][\pLl\pN\-_.]*$`
 	if ok := regexp.MustCompile(p).MatchString(str); !ok {
 		return fmt.Errorf("wanted string matching %v", p)
 	}
+	// the environment's lock document would be the one locking every environment
+	if str == es.GlobalLockId {
+		return fmt.Errorf("%v is reserved", str)
+	}
 	return nil
 }
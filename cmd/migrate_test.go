@@ -17,6 +17,7 @@ func Test_validateMigrateArgs(t *testing.T) {
 		{in: []string{"x", "y"}, wantValid: false},
 		{in: []string{"x"}, wantValid: true},
 		{in: []string{"x-y.z"}, wantValid: true},
+		{in: []string{"LOCK"}, wantValid: false},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/hdpe.me/esup/util"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+)
+
+// changesPendingExitCode is returned by the plan command when the schema isn't up to date
+const changesPendingExitCode = 2
+
+var planFile string
+
+func init() {
+	planCmd.Flags().StringVarP(&version, "version", "v",
+		(&util.DefaultClock{}).Now().UTC().Format("20060102150405"),
+		"index version suffix for this migration - defaults to current timestamp")
+
+	planCmd.Flags().StringVar(&output, "output", "text",
+		"format in which to print the plan - text or json")
+
+	planCmd.Flags().BoolVar(&prune, "prune", false,
+		"remove resources which are no longer in the schema")
+
+	planCmd.Flags().BoolVar(&checkDrift, "check-drift", false,
+		"check for changes made outside of esup before planning")
+
+	planCmd.Flags().StringVar(&planFile, "out", "",
+		"save the plan to this file, to be executed later by apply")
+
+	rootCmd.AddCommand(planCmd)
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan ENVIRONMENT",
+	Short: "Show the changes a migration of an esup schema would make, without making them",
+	Long: fmt.Sprintf("Show the changes a migration of an esup schema would make, without making them.\n\n"+
+		"Exits with status %v if there are changes pending, or drift when checked for.", changesPendingExitCode),
+	Args: validateEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		ctx := newContext(envName)
+
+		planner := plan.NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, ctx.Schema, ctx.Proc, version)
+		planner.SetPrune(prune)
+
+		var drift []plan.Drift
+
+		if checkDrift {
+			var err error
+			drift, err = planner.Drift()
+
+			if err != nil {
+				return fmt.Errorf("couldn't detect drift: %w", err)
+			}
+
+			logDrift(drift, ctx.Conf.Server)
+		}
+
+		resPlan, err := planner.Plan()
+
+		if err != nil {
+			return fmt.Errorf("couldn't plan update: %w", err)
+		}
+
+		if err = printPlan(resPlan, ctx.Conf.Server, output); err != nil {
+			return err
+		}
+
+		if planFile != "" {
+			b, err := json.MarshalIndent(planner.SavedPlan(resPlan), "", "  ")
+
+			if err != nil {
+				return fmt.Errorf("couldn't marshal plan: %w", err)
+			}
+
+			if err = ioutil.WriteFile(planFile, b, 0644); err != nil {
+				return fmt.Errorf("couldn't write plan to %v: %w", planFile, err)
+			}
+		}
+
+		if len(resPlan) > 0 || len(drift) > 0 {
+			os.Exit(changesPendingExitCode)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rollbackCmd.Flags().BoolVarP(&approve, "approve", "a", false,
+		"approve this rollback without prompting")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback ENVIRONMENT INDEX_SET",
+	Short: "Point an index set's alias back to its previous index",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		return validateEnv(args[0])
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		indexSet := args[1]
+
+		ctx := newContext(envName)
+
+		planner := plan.NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, ctx.Schema, ctx.Proc, "")
+
+		interrupt, stop := interruptible()
+		defer stop()
+
+		getInterruptibleLock(ctx, envName, interrupt)
+		defer releaseLock(ctx, envName)
+
+		interrupt = untilLockLost(interrupt, ctx.Lock)
+
+		resPlan, err := planner.PlanRollback(indexSet)
+
+		if err != nil {
+			return fmt.Errorf("couldn't plan rollback: %w", err)
+		}
+
+		logPlan(resPlan, ctx.Conf.Server)
+
+		if !approve && !confirm(interrupt) {
+			return cancelled(interrupt)
+		}
+
+		if err = executePlan(ctx, resPlan, interrupt); err != nil {
+			return err
+		}
+
+		println("Complete")
+		return nil
+	},
+}
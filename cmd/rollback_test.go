@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_validateRollbackArgs(t *testing.T) {
+	testCases := []struct {
+		in        []string
+		wantValid bool
+	}{
+		{in: []string{}, wantValid: false},
+		{in: []string{"x"}, wantValid: false},
+		{in: []string{"", "i"}, wantValid: false},
+		{in: []string{"-x", "i"}, wantValid: false},
+		{in: []string{"x", "i", "y"}, wantValid: false},
+		{in: []string{"x", "i"}, wantValid: true},
+		{in: []string{"x-y.z", "i"}, wantValid: true},
+	}
+
+	for _, tc := range testCases {
+		err := rollbackCmd.Args(nil, tc.in)
+		if valid := err == nil; valid != tc.wantValid {
+			t.Errorf("%q valid? got %v, want %v", tc.in, valid, tc.wantValid)
+		}
+	}
+}
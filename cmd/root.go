@@ -1,12 +1,22 @@
 package cmd
 
 import (
+	stdcontext "context"
+	"errors"
 	"fmt"
 	"github.com/hdpe.me/esup/context"
+	"github.com/hdpe.me/esup/resource"
+	"github.com/hdpe.me/esup/util"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
+var lockTimeout time.Duration
+
+// maxLockWait is the longest to wait between attempts to get the lock
+const maxLockWait = 30 * time.Second
+
 var rootCmd = &cobra.Command{
 	Use:   "esup",
 	Short: "esup is a schema migration tool for Elasticsearch",
@@ -27,11 +37,68 @@ func fatalError(format string, a ...interface{}) {
 }
 
 func getLock(ctx *context.Context, envName string) {
-	if err := ctx.Lock.Get(envName); err != nil {
+	getInterruptibleLock(ctx, envName, stdcontext.Background())
+}
+
+// getInterruptibleLock gets the lock like getLock, but gives up waiting for it once interrupt is cancelled
+func getInterruptibleLock(ctx *context.Context, envName string, interrupt stdcontext.Context) {
+	get := func() error {
+		if err := interrupt.Err(); err != nil {
+			return err
+		}
+		return ctx.Lock.Get(envName)
+	}
+
+	sleep := func(d time.Duration) {
+		select {
+		case <-time.After(d):
+		case <-interrupt.Done():
+		}
+	}
+
+	if err := waitForLock(get, lockTimeout, &util.DefaultClock{}, sleep); err != nil {
 		fatalError("couldn't get lock: %v", err)
 	}
 }
 
+// waitForLock tries to get the lock until it succeeds or the timeout expires while another process holds it, waiting
+// twice as long after each attempt
+func waitForLock(get func() error, timeout time.Duration, clock util.Clock, sleep func(time.Duration)) error {
+	started := clock.Now()
+	wait := time.Second
+
+	for {
+		err := get()
+
+		var lockedErr *resource.LockedError
+		if err == nil || !errors.As(err, &lockedErr) {
+			return err
+		}
+
+		waited := clock.Now().Sub(started)
+
+		if waited >= timeout {
+			if timeout > 0 {
+				return fmt.Errorf("gave up waiting after %v: %w", timeout, err)
+			}
+			return err
+		}
+
+		println(fmt.Sprintf("Waiting for lock held by %v since %v - waited %v", lockedErr.Entry.Holder(),
+			lockedErr.Entry.Timestamp, waited.Round(time.Second)))
+
+		if remaining := timeout - waited; wait > remaining {
+			wait = remaining
+		}
+
+		sleep(wait)
+
+		if wait *= 2; wait > maxLockWait {
+			wait = maxLockWait
+		}
+	}
+}
+
 func releaseLock(ctx *context.Context, envName string) {
 	if err := ctx.Lock.Release(envName); err != nil {
 		fatalError("couldn't release lock: %v", err)
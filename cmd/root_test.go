@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/hdpe.me/esup/resource"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_waitForLock(t *testing.T) {
+	locked := &resource.LockedError{}
+	other := errors.New("other")
+
+	testCases := []struct {
+		desc      string
+		results   []error
+		timeout   time.Duration
+		wantErr   error
+		wantWaits []time.Duration
+	}{
+		{
+			desc:      "gets lock straight away",
+			results:   []error{nil},
+			timeout:   10 * time.Minute,
+			wantWaits: []time.Duration{},
+		},
+		{
+			desc:      "fails straight away without timeout",
+			results:   []error{locked},
+			wantErr:   locked,
+			wantWaits: []time.Duration{},
+		},
+		{
+			desc:      "fails straight away with other error",
+			results:   []error{other},
+			timeout:   10 * time.Minute,
+			wantErr:   other,
+			wantWaits: []time.Duration{},
+		},
+		{
+			desc:      "gets lock after backing off",
+			results:   []error{locked, locked, locked, nil},
+			timeout:   10 * time.Minute,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			desc:      "gives up once timeout expires",
+			results:   []error{locked, locked, locked, locked, nil},
+			timeout:   5 * time.Second,
+			wantErr:   locked,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 2 * time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)}
+			waits := make([]time.Duration, 0)
+			attempt := 0
+
+			get := func() error {
+				err := tc.results[attempt]
+				attempt++
+				return err
+			}
+
+			sleep := func(d time.Duration) {
+				waits = append(waits, d)
+				clock.now = clock.now.Add(d)
+			}
+
+			err := waitForLock(get, tc.timeout, clock, sleep)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if !reflect.DeepEqual(waits, tc.wantWaits) {
+				t.Errorf("got waits %v, want %v", waits, tc.wantWaits)
+			}
+		})
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/plan"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status ENVIRONMENT",
+	Short: "Show the status of every resource in an esup schema",
+	Args:  validateEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		ctx := newContext(envName)
+
+		planner := plan.NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, ctx.Schema, ctx.Proc, version)
+
+		statuses, err := planner.Status()
+
+		if err != nil {
+			return fmt.Errorf("couldn't get status: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		_, _ = fmt.Fprintln(w, "TYPE\tRESOURCE\tNAME\tINDICES\tDOCS\tSIZE\tLAST CHANGELOG\tFINAL NAME\tSTATE")
+
+		for _, s := range statuses {
+			docs, size := "", ""
+
+			if s.Resource.Type == "index_set" {
+				docs = fmt.Sprintf("%v", s.DocsCount)
+				size = formatBytes(s.StoreSize)
+			}
+
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", s.Resource.Type, s.Resource.Identifier,
+				s.Name, strings.Join(s.Indices, ","), docs, size, orDash(s.ChangelogTimestamp),
+				orDash(s.ChangelogFinalName), s.State)
+		}
+
+		return w.Flush()
+	},
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%vb", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cb", float64(b)/float64(div), "kmgtpe"[exp])
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
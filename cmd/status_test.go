@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_formatBytes(t *testing.T) {
+	testCases := []struct {
+		in   int64
+		want string
+	}{
+		{in: 0, want: "0b"},
+		{in: 1023, want: "1023b"},
+		{in: 1024, want: "1.0kb"},
+		{in: 1536, want: "1.5kb"},
+		{in: 5 * 1024 * 1024 * 1024, want: "5.0gb"},
+	}
+
+	for _, tc := range testCases {
+		if got := formatBytes(tc.in); got != tc.want {
+			t.Errorf("formatBytes(%v): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"strconv"
+)
+
+func init() {
+	rootCmd.AddCommand(throttleCmd)
+}
+
+var throttleCmd = &cobra.Command{
+	Use:   "throttle TASK_ID REQUESTS_PER_SECOND",
+	Short: "Change the throttle of a running reindex - -1 removes it",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		_, err := parseRequestsPerSecond(args[1])
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		taskId := args[0]
+		requestsPerSecond, _ := parseRequestsPerSecond(args[1])
+
+		_, esClient := newClient()
+
+		if err := esClient.Rethrottle(taskId, requestsPerSecond); err != nil {
+			return err
+		}
+
+		if requestsPerSecond == -1 {
+			println(fmt.Sprintf("Removed throttle from task %v", taskId))
+		} else {
+			println(fmt.Sprintf("Throttled task %v to %v requests per second", taskId, requestsPerSecond))
+		}
+
+		return nil
+	},
+}
+
+func parseRequestsPerSecond(str string) (int, error) {
+	requestsPerSecond, err := strconv.Atoi(str)
+
+	if err != nil || (requestsPerSecond < 1 && requestsPerSecond != -1) {
+		return 0, fmt.Errorf("invalid requests per second %q - wanted a positive number, or -1 for unthrottled", str)
+	}
+
+	return requestsPerSecond, nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_parseRequestsPerSecond(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "500", want: 500},
+		{in: "-1", want: -1},
+		{in: "0", wantErr: true},
+		{in: "-2", wantErr: true},
+		{in: "fast", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseRequestsPerSecond(tc.in)
+
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseRequestsPerSecond(%q): got error %v, want error %v", tc.in, err, tc.wantErr)
+		}
+
+		if got != tc.want {
+			t.Errorf("parseRequestsPerSecond(%q): got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	viperlib "github.com/spf13/viper"
 	"strings"
+	"time"
 )
 
 func NewConfig() (Config, error) {
@@ -11,8 +12,12 @@ func NewConfig() (Config, error) {
 	viper.SetDefault("server.address", "http://localhost:9200")
 	viper.SetDefault("changelog.index", "esup-changelog0")
 	viper.SetDefault("changelog.lockIndex", "esup-lock0")
+	viper.SetDefault("changelog.journalIndex", "esup-journal0")
+	viper.SetDefault("changelog.lockLease", "2m")
 	viper.SetDefault("pipelines.directory", "./pipelines")
 	viper.SetDefault("indexSets.directory", "./indexSets")
+	viper.SetDefault("indexSets.concurrency", 1)
+	viper.SetDefault("indexSets.health.timeout", "5m")
 	viper.SetDefault("documents.directory", "./documents")
 	viper.SetDefault("preprocess.includesDirectory", "./includes")
 
@@ -30,6 +35,12 @@ func NewConfig() (Config, error) {
 	viper.AutomaticEnv()
 	viper.AllowEmptyEnv(true)
 
+	// the lease is renewed a third of the way through, and an unparsable value reads as 0
+	if lease := viper.GetDuration("changelog.lockLease"); lease <= 0 {
+		return Config{}, fmt.Errorf("changelog.lockLease should be a positive duration, e.g. 2m, not %q",
+			viper.GetString("changelog.lockLease"))
+	}
+
 	return Config{
 		ServerConfig{
 			Address: viper.GetString("server.address"),
@@ -37,10 +48,30 @@ func NewConfig() (Config, error) {
 		},
 		PrototypeConfig{Environment: viper.GetString("prototype.environment")},
 		ChangelogConfig{
-			Index:     viper.GetString("changelog.index"),
-			LockIndex: viper.GetString("changelog.lockIndex"),
+			Index:        viper.GetString("changelog.index"),
+			LockIndex:    viper.GetString("changelog.lockIndex"),
+			JournalIndex: viper.GetString("changelog.journalIndex"),
+			LockLease:    viper.GetDuration("changelog.lockLease"),
+			GlobalLock:   viper.GetBool("changelog.globalLock"),
+		},
+		IndexSetsConfig{
+			Directory:   viper.GetString("indexSets.directory"),
+			Concurrency: viper.GetInt("indexSets.concurrency"),
+			Retention: RetentionConfig{
+				Keep:      viper.GetInt("indexSets.retention.keep"),
+				Action:    viper.GetString("indexSets.retention.action"),
+				AfterDays: viper.GetInt("indexSets.retention.afterDays"),
+			},
+			Reindex: ReindexConfig{
+				RequestsPerSecond: viper.GetInt("indexSets.reindex.requestsPerSecond"),
+				Slices:            viper.GetString("indexSets.reindex.slices"),
+				BatchSize:         viper.GetInt("indexSets.reindex.batchSize"),
+			},
+			Health: HealthConfig{
+				Status:  viper.GetString("indexSets.health.status"),
+				Timeout: viper.GetDuration("indexSets.health.timeout"),
+			},
 		},
-		IndexSetsConfig{Directory: viper.GetString("indexSets.directory")},
 		PipelinesConfig{Directory: viper.GetString("pipelines.directory")},
 		DocumentsConfig{Directory: viper.GetString("documents.directory")},
 		PreprocessConfig{IncludesDirectory: viper.GetString("preprocess.includesDirectory")},
@@ -67,14 +98,58 @@ type PrototypeConfig struct {
 }
 
 type ChangelogConfig struct {
-	Index     string
-	LockIndex string
+	Index        string
+	LockIndex    string
+	JournalIndex string
+	// LockLease is how long the lock is held for without being renewed before another process can take it over
+	LockLease time.Duration
+	// GlobalLock is whether to lock every environment at once, rather than just the one being changed
+	GlobalLock bool
 }
 
 type IndexSetsConfig struct {
 	Directory string
+	// Concurrency is the most index sets migrated at once
+	Concurrency int
+	Retention   RetentionConfig
+	Reindex     ReindexConfig
+	Health      HealthConfig
 }
 
+// RetentionConfig is the default retention policy for superseded indices of index sets
+type RetentionConfig struct {
+	// Keep is the number of most recently superseded indices to leave alone
+	Keep int
+	// Action is "close", "readOnly" or "delete", or "" to leave superseded indices alone
+	Action string
+	// AfterDays is the number of days after an index is superseded before Action is taken
+	AfterDays int
+}
+
+// ReindexConfig is the default tuning of reindexing for index sets
+type ReindexConfig struct {
+	// RequestsPerSecond throttles reindexing, or is 0 to leave it unthrottled
+	RequestsPerSecond int
+	// Slices is the number of slices to divide reindexing into, "auto", or "" to not slice it
+	Slices string
+	// BatchSize is the number of documents copied in each batch, or 0 for the Elasticsearch default
+	BatchSize int
+}
+
+// HealthConfig is the default health new indices of index sets must reach before their alias is pointed at them
+type HealthConfig struct {
+	// Status is "green" or "yellow", or "" to not wait for health
+	Status string
+	// Timeout is how long to wait for the status before failing the migration
+	Timeout time.Duration
+}
+
+const (
+	HealthGreen  = "green"
+	HealthYellow = "yellow"
+	HealthRed    = "red"
+)
+
 type PipelinesConfig struct {
 	Directory string
 }
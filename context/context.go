@@ -13,5 +13,6 @@ type Context struct {
 	Es        *es.Client
 	Changelog *resource.Changelog
 	Lock      *resource.Lock
+	Journal   *resource.Journal
 	Proc      *resource.Preprocessor
 }
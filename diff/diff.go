@@ -1,20 +1,158 @@
 package diff
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/yudai/gojsondiff"
+	"sort"
 )
 
 func Diff(required string, current string) (bool, error) {
+	changes, err := Changes(required, current)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(changes) > 0, nil
+}
+
+// Changes returns the differences between the current and required JSON documents, ordered by path
+func Changes(required string, current string) ([]Change, error) {
 	if required == "" || current == "" {
-		return required != current, nil
+		switch {
+		case required == current:
+			return nil, nil
+		case required == "":
+			return []Change{{Type: Removed, OldValue: decodeOrRaw(current)}}, nil
+		default:
+			return []Change{{Type: Added, NewValue: decodeOrRaw(required)}}, nil
+		}
 	}
 
 	differ := gojsondiff.New()
-	compare, err := differ.Compare([]byte(required), []byte(current))
+	compare, err := differ.Compare([]byte(current), []byte(required))
 
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+	collectChanges("", compare.Deltas(), &changes)
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+func collectChanges(parentPath string, deltas []gojsondiff.Delta, changes *[]Change) {
+	for _, d := range deltas {
+		switch d := d.(type) {
+		case *gojsondiff.Object:
+			collectChanges(childPath(parentPath, d.PostPosition()), d.Deltas, changes)
+		case *gojsondiff.Array:
+			collectChanges(childPath(parentPath, d.PostPosition()), d.Deltas, changes)
+		case *gojsondiff.Added:
+			*changes = append(*changes, Change{
+				Path:     childPath(parentPath, d.PostPosition()),
+				Type:     Added,
+				NewValue: d.Value,
+			})
+		case *gojsondiff.Deleted:
+			*changes = append(*changes, Change{
+				Path:     childPath(parentPath, d.PrePosition()),
+				Type:     Removed,
+				OldValue: d.Value,
+			})
+		case *gojsondiff.Modified:
+			*changes = append(*changes, Change{
+				Path:     childPath(parentPath, d.PostPosition()),
+				Type:     Modified,
+				OldValue: d.OldValue,
+				NewValue: d.NewValue,
+			})
+		case *gojsondiff.TextDiff:
+			*changes = append(*changes, Change{
+				Path:     childPath(parentPath, d.PostPosition()),
+				Type:     Modified,
+				OldValue: d.OldValue,
+				NewValue: d.NewValue,
+			})
+		case *gojsondiff.Moved:
+			*changes = append(*changes, Change{
+				Path:     childPath(parentPath, d.PostPosition()),
+				Type:     Moved,
+				OldValue: d.Value,
+				NewValue: d.Value,
+			})
+		}
+	}
+}
+
+func childPath(parentPath string, position gojsondiff.Position) string {
+	if index, ok := position.(gojsondiff.Index); ok {
+		return fmt.Sprintf("%v[%v]", parentPath, int(index))
+	}
+	if parentPath == "" {
+		return position.String()
+	}
+	return fmt.Sprintf("%v.%v", parentPath, position.String())
+}
+
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+	Moved    ChangeType = "moved"
+)
+
+// Change is a single difference between two JSON documents at the given path - a path of "" denotes the whole
+// document
+type Change struct {
+	Path     string      `json:"path"`
+	Type     ChangeType  `json:"type"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "(document)"
+	}
+
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %v: %v", path, valueString(c.NewValue))
+	case Removed:
+		return fmt.Sprintf("- %v: %v", path, valueString(c.OldValue))
+	case Moved:
+		return fmt.Sprintf("~ %v: moved", path)
+	default:
+		return fmt.Sprintf("~ %v: %v -> %v", path, valueString(c.OldValue), valueString(c.NewValue))
+	}
+}
+
+func decodeOrRaw(doc string) interface{} {
+	var value interface{}
+
+	if err := json.Unmarshal([]byte(doc), &value); err != nil {
+		return doc
+	}
+
+	return value
+}
+
+func valueString(value interface{}) string {
+	b, err := json.Marshal(value)
+
+	if err != nil {
+		return fmt.Sprintf("%v", value)
 	}
 
-	return compare.Modified(), nil
+	return string(b)
 }
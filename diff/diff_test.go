@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		required string
+		current  string
+		expected []string
+	}{
+		{
+			desc:     "identical documents",
+			required: `{"a":{"b":1}}`,
+			current:  `{"a":{"b":1}}`,
+			expected: []string{},
+		},
+		{
+			desc:     "both empty",
+			required: "",
+			current:  "",
+			expected: nil,
+		},
+		{
+			desc:     "new document",
+			required: `{"a":1}`,
+			current:  "",
+			expected: []string{`+ (document): {"a":1}`},
+		},
+		{
+			desc:     "removed document",
+			required: "",
+			current:  `{"a":1}`,
+			expected: []string{`- (document): {"a":1}`},
+		},
+		{
+			desc:     "nested changes ordered by path",
+			required: `{"mappings":{"properties":{"x":{"type":"keyword"},"z":{"type":"text"}}},"settings":{}}`,
+			current:  `{"mappings":{"properties":{"x":{"type":"text"},"y":{"type":"text"}}},"settings":{}}`,
+			expected: []string{
+				`~ mappings.properties.x.type: "text" -> "keyword"`,
+				`- mappings.properties.y: {"type":"text"}`,
+				`+ mappings.properties.z: {"type":"text"}`,
+			},
+		},
+		{
+			desc:     "array element changed",
+			required: `{"processors":[{"set":{"field":"a"}},{"set":{"field":"c"}}]}`,
+			current:  `{"processors":[{"set":{"field":"a"}},{"set":{"field":"b"}}]}`,
+			expected: []string{
+				`~ processors[1].set.field: "b" -> "c"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			changes, err := Changes(tc.required, tc.current)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			if changes != nil {
+				got = make([]string, 0)
+			}
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -4,13 +4,29 @@ import (
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/hdpe.me/esup/util"
+	"sort"
 	"time"
 )
 
 type ChangelogEntry struct {
 	IsPresent bool
-	Content   string
-	Meta      string
+	// ResourceType, ResourceIdentifier and EnvName are read from the changelog; they're not needed to put an entry
+	ResourceType       string
+	ResourceIdentifier string
+	EnvName            string
+	Content            string
+	Meta               string
+	// FinalName is the name of the resource in Elasticsearch, as read from the changelog
+	FinalName string
+	// Timestamp is the time the entry was written, as read from the changelog
+	Timestamp string
+	// Deleted marks a tombstone entry, recording that the resource was removed
+	Deleted bool
+}
+
+// Time parses the entry's Timestamp
+func (e ChangelogEntry) Time() (time.Time, error) {
+	return ParseTimestamp(e.Timestamp)
 }
 
 func CreateChangelogIndex(es *Client, indexName string) error {
@@ -37,6 +53,9 @@ func CreateChangelogIndex(es *Client, indexName string) error {
 			},
 			"timestamp": {
 				"type": "date"
+			},
+			"deleted": {
+				"type": "boolean"
 			}
 		}
 	}
@@ -46,34 +65,7 @@ func CreateChangelogIndex(es *Client, indexName string) error {
 func GetChangelogEntry(es *Client, indexName string, resourceType string, resourceIdentifier string,
 	envName string) (ChangelogEntry, error) {
 
-	body := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"resource_type": resourceType,
-						},
-					},
-					{
-						"term": map[string]interface{}{
-							"resource_identifier": resourceIdentifier,
-						},
-					},
-					{
-						"term": map[string]interface{}{
-							"env_name": envName,
-						},
-					},
-				},
-			},
-		},
-		"sort": map[string]interface{}{
-			"timestamp": map[string]interface{}{
-				"order": "desc",
-			},
-		},
-	}
+	body := changelogEntriesQuery(resourceType, resourceIdentifier, envName, "desc")
 
 	res, err := es.Search(indexName, body, func(request *esapi.SearchRequest) {
 		request.Size = util.Intptr(1)
@@ -83,17 +75,166 @@ func GetChangelogEntry(es *Client, indexName string, resourceType string, resour
 		return ChangelogEntry{}, fmt.Errorf("couldn't get changelog entry: %w", err)
 	}
 
-	if len(res) == 0 {
+	// a resource whose latest entry is a tombstone is as good as never having been in the changelog
+	if len(res) == 0 || res[0].source.Get("deleted").Bool() {
 		return ChangelogEntry{}, nil
 	}
 
-	source := res[0].source
+	return newChangelogEntry(res[0]), nil
+}
+
+// GetChangelogEntries returns every changelog entry for a resource, oldest first
+func GetChangelogEntries(es *Client, indexName string, resourceType string, resourceIdentifier string,
+	envName string) ([]ChangelogEntry, error) {
+
+	body := changelogEntriesQuery(resourceType, resourceIdentifier, envName, "asc")
+
+	return searchChangelogEntries(es, indexName, body)
+}
+
+// GetAllChangelogEntries returns every changelog entry for every environment, oldest first. Unlike the other
+// queries it pages through the entries, so none are left out however long the changelog grows.
+func GetAllChangelogEntries(es *Client, indexName string) ([]ChangelogEntry, error) {
+	entries := make([]ChangelogEntry, 0)
+	var after interface{}
+
+	for {
+		body := map[string]interface{}{
+			"query": map[string]interface{}{
+				"match_all": map[string]interface{}{},
+			},
+			// the fields after the timestamp break ties, so no entry falls between pages
+			"sort": []string{"timestamp", "env_name", "resource_type", "resource_identifier", "final_name"},
+		}
+
+		if after != nil {
+			body["search_after"] = after
+		}
+
+		res, err := es.Search(indexName, body, func(request *esapi.SearchRequest) {
+			request.Size = util.Intptr(maxChangelogEntries)
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get changelog entries: %w", err)
+		}
+
+		for _, doc := range res {
+			entries = append(entries, newChangelogEntry(doc))
+		}
+
+		if len(res) < maxChangelogEntries {
+			return entries, nil
+		}
+
+		after = res[len(res)-1].sort.Value()
+	}
+}
+
+// GetLatestChangelogEntries returns the latest changelog entry, which may be a tombstone, for each resource in an
+// environment
+func GetLatestChangelogEntries(es *Client, indexName string, envName string) ([]ChangelogEntry, error) {
+	body := changelogQuery(map[string]string{"env_name": envName}, "desc")
+
+	entries, err := searchChangelogEntries(es, indexName, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make([]ChangelogEntry, 0)
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		key := fmt.Sprintf("%v:%v", entry.ResourceType, entry.ResourceIdentifier)
+
+		if !seen[key] {
+			latest = append(latest, entry)
+			seen[key] = true
+		}
+	}
+
+	return latest, nil
+}
+
+// maxChangelogEntries is the most entries we can read in one search
+var maxChangelogEntries = 10000
+
+func searchChangelogEntries(es *Client, indexName string, body map[string]interface{}) ([]ChangelogEntry, error) {
+	res, err := es.Search(indexName, body, func(request *esapi.SearchRequest) {
+		request.Size = util.Intptr(maxChangelogEntries)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get changelog entries: %w", err)
+	}
+
+	entries := make([]ChangelogEntry, 0)
+
+	for _, doc := range res {
+		entries = append(entries, newChangelogEntry(doc))
+	}
+
+	return entries, nil
+}
+
+func changelogEntriesQuery(resourceType string, resourceIdentifier string, envName string,
+	order string) map[string]interface{} {
+
+	return changelogQuery(map[string]string{
+		"resource_type":       resourceType,
+		"resource_identifier": resourceIdentifier,
+		"env_name":            envName,
+	}, order)
+}
+
+func changelogQuery(terms map[string]string, order string) map[string]interface{} {
+	fields := make([]string, 0)
+
+	for field := range terms {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	must := make([]map[string]interface{}, 0)
+
+	for _, field := range fields {
+		must = append(must, map[string]interface{}{
+			"term": map[string]interface{}{
+				field: terms[field],
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": must,
+			},
+		},
+		"sort": map[string]interface{}{
+			"timestamp": map[string]interface{}{
+				"order": order,
+			},
+		},
+	}
+}
+
+func newChangelogEntry(doc Document) ChangelogEntry {
+	source := doc.source
 
 	return ChangelogEntry{
-		IsPresent: true,
-		Content:   source.Get("content").String(),
-		Meta:      source.Get("meta").String(),
-	}, nil
+		IsPresent:          true,
+		ResourceType:       source.Get("resource_type").String(),
+		ResourceIdentifier: source.Get("resource_identifier").String(),
+		EnvName:            source.Get("env_name").String(),
+		Content:            source.Get("content").String(),
+		Meta:               source.Get("meta").String(),
+		FinalName:          source.Get("final_name").String(),
+		Timestamp:          source.Get("timestamp").String(),
+		Deleted:            source.Get("deleted").Bool(),
+	}
 }
 
 func PutChangelogEntry(es *Client, indexName string, resourceType string, resourceIdentifier string, finalName string,
@@ -107,6 +248,7 @@ func PutChangelogEntry(es *Client, indexName string, resourceType string, resour
 		"meta":                entry.Meta,
 		"env_name":            envName,
 		"timestamp":           time.Now().UTC().Format(systemTimestampLayout),
+		"deleted":             entry.Deleted,
 	}
 
 	if err := es.IndexDocument(indexName, "", body); err != nil {
@@ -2,6 +2,7 @@ package es
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
@@ -10,7 +11,10 @@ import (
 	"github.com/hdpe.me/esup/util"
 	"github.com/tidwall/gjson"
 	"io"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 func NewClient(serverConfig config.ServerConfig) (*Client, error) {
@@ -31,9 +35,15 @@ func NewClient(serverConfig config.ServerConfig) (*Client, error) {
 		}
 	}
 
+	transport := &contextTransport{
+		transport: http.DefaultTransport,
+		ctx:       context.Background(),
+	}
+
 	clientConfig := elasticsearch.Config{
 		Addresses: []string{serverConfig.Address},
 		APIKey:    apiKey,
+		Transport: transport,
 	}
 
 	client, err := elasticsearch.NewClient(clientConfig)
@@ -42,11 +52,45 @@ func NewClient(serverConfig config.ServerConfig) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{client}, nil
+	return &Client{client, transport}, nil
 }
 
 type Client struct {
-	client *elasticsearch.Client
+	client    *elasticsearch.Client
+	transport *contextTransport
+}
+
+// SetContext sets the context with which subsequent requests are made, so they can be cancelled
+func (r *Client) SetContext(ctx context.Context) {
+	r.transport.setContext(ctx)
+}
+
+// Context returns the context with which requests are made
+func (r *Client) Context() context.Context {
+	return r.transport.context()
+}
+
+// contextTransport makes each request with the context most recently set
+type contextTransport struct {
+	transport http.RoundTripper
+	mu        sync.Mutex
+	ctx       context.Context
+}
+
+func (t *contextTransport) setContext(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = ctx
+}
+
+func (t *contextTransport) context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ctx
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport.RoundTrip(req.WithContext(t.context()))
 }
 
 func (r *Client) Search(indexName string, body map[string]interface{}, o ...func(*esapi.SearchRequest)) ([]Document, error) {
@@ -113,6 +157,20 @@ func (r *Client) IndexDocument(indexName string, id string, body map[string]inte
 	return nil
 }
 
+func (r *Client) DeleteDocument(indexName string, id string) error {
+	res, err := r.client.Delete(indexName, id)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = getBodyOrEmptyAndVerifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't delete document %v/%v: %w", indexName, id, err)
+	}
+
+	return nil
+}
+
 func (r *Client) GetDocument(indexName string, id string) (Document, error) {
 	res, err := r.client.Get(indexName, id)
 
@@ -167,6 +225,40 @@ func (r *Client) GetIndicesForAlias(alias string) ([]string, error) {
 	return result, nil
 }
 
+// GetAliases returns the aliases of each index matching an index expression, including indices with no aliases
+func (r *Client) GetAliases(expression string) (map[string][]string, error) {
+	res, err := r.client.Indices.GetAlias(func(req *esapi.IndicesGetAliasRequest) {
+		req.Index = []string{expression}
+		req.ExpandWildcards = "all"
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getBodyOrEmptyAndVerifyResponse(res)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get aliases for %v: %w", expression, err)
+	}
+
+	result := make(map[string][]string)
+
+	gjson.Parse(body).ForEach(func(index, value gjson.Result) bool {
+		aliases := make([]string, 0)
+
+		value.Get("aliases").ForEach(func(alias, _ gjson.Result) bool {
+			aliases = append(aliases, alias.String())
+			return true
+		})
+
+		result[index.String()] = aliases
+		return true
+	})
+
+	return result, nil
+}
+
 func (r *Client) GetIndexDef(index string) (string, error) {
 	res, err := r.client.Indices.Get([]string{index})
 
@@ -183,6 +275,35 @@ func (r *Client) GetIndexDef(index string) (string, error) {
 	return body, nil
 }
 
+// GetIndices returns information about the indices matching an index expression, or an empty slice if none do
+func (r *Client) GetIndices(expression string) ([]IndexInfo, error) {
+	res, err := r.client.Cat.Indices(func(req *esapi.CatIndicesRequest) {
+		req.Index = []string{expression}
+		req.Format = "json"
+		req.Bytes = "b"
+		req.H = []string{"index", "status", "docs.count", "store.size", "creation.date"}
+		req.ExpandWildcards = "all"
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getBodyOrEmptyAndVerifyResponse(res)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get indices %v: %w", expression, err)
+	}
+
+	result := make([]IndexInfo, 0)
+
+	for _, index := range gjson.Parse(body).Array() {
+		result = append(result, newIndexInfo(index))
+	}
+
+	return result, nil
+}
+
 func (r *Client) CreateIndex(index string, mapping string) error {
 	res, err := r.client.Indices.Create(index, func(req *esapi.IndicesCreateRequest) {
 		req.Body = strings.NewReader(mapping)
@@ -199,14 +320,71 @@ func (r *Client) CreateIndex(index string, mapping string) error {
 	return nil
 }
 
-func (r *Client) Reindex(fromIndex string, toIndex string, maxDocs int, pipeline string) (string, error) {
+func (r *Client) PutMapping(index string, mapping string) error {
+	res, err := r.client.Indices.PutMapping(strings.NewReader(mapping), func(req *esapi.IndicesPutMappingRequest) {
+		req.Index = []string{index}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't put mapping for index %v: %w", index, err)
+	}
+
+	return nil
+}
+
+func (r *Client) PutSettings(index string, settings string) error {
+	res, err := r.client.Indices.PutSettings(strings.NewReader(settings), func(req *esapi.IndicesPutSettingsRequest) {
+		req.Index = []string{index}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't put settings for index %v: %w", index, err)
+	}
+
+	return nil
+}
+
+// ReindexOptions control how Reindex copies documents
+type ReindexOptions struct {
+	// MaxDocs is the most documents to copy, or -1 to copy all of them
+	MaxDocs  int
+	Pipeline string
+	// RequestsPerSecond throttles the reindex, or is 0 to leave it unthrottled
+	RequestsPerSecond int
+	// Slices is the number of slices to divide the reindex into, "auto", or "" to not slice it
+	Slices string
+	// BatchSize is the number of documents copied in each batch, or 0 for the Elasticsearch default
+	BatchSize int
+	// Query selects the documents to copy, or is nil to copy all of them
+	Query map[string]interface{}
+}
+
+func (r *Client) Reindex(fromIndex string, toIndex string, options ReindexOptions) (string, error) {
+	source := map[string]interface{}{
+		"index": fromIndex,
+	}
+
+	if options.BatchSize > 0 {
+		source["size"] = options.BatchSize
+	}
+
+	if options.Query != nil {
+		source["query"] = options.Query
+	}
+
 	body := map[string]interface{}{
-		"source": map[string]interface{}{
-			"index": fromIndex,
-		},
+		"source": source,
 		"dest": map[string]interface{}{
 			"index":    toIndex,
-			"pipeline": pipeline,
+			"pipeline": options.Pipeline,
 		},
 	}
 
@@ -218,8 +396,14 @@ func (r *Client) Reindex(fromIndex string, toIndex string, maxDocs int, pipeline
 
 	res, err := r.client.Reindex(&buf, func(request *esapi.ReindexRequest) {
 		request.WaitForCompletion = util.Boolptr(false)
-		if maxDocs != -1 {
-			request.MaxDocs = util.Intptr(maxDocs)
+		if options.MaxDocs != -1 {
+			request.MaxDocs = util.Intptr(options.MaxDocs)
+		}
+		if options.RequestsPerSecond != 0 {
+			request.RequestsPerSecond = util.Intptr(options.RequestsPerSecond)
+		}
+		if options.Slices != "" {
+			request.Slices = options.Slices
 		}
 	})
 
@@ -242,6 +426,21 @@ func (r *Client) Reindex(fromIndex string, toIndex string, maxDocs int, pipeline
 	return task.String(), nil
 }
 
+// Rethrottle changes the requests per second of a running reindex task, where -1 removes the throttle
+func (r *Client) Rethrottle(taskId string, requestsPerSecond int) error {
+	res, err := r.client.ReindexRethrottle(taskId, util.Intptr(requestsPerSecond))
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't rethrottle task %v: %w", taskId, err)
+	}
+
+	return nil
+}
+
 func (r *Client) DeleteIndex(id string) error {
 	res, err := r.client.Indices.Delete([]string{id})
 
@@ -256,6 +455,58 @@ func (r *Client) DeleteIndex(id string) error {
 	return nil
 }
 
+func (r *Client) CloseIndex(index string) error {
+	res, err := r.client.Indices.Close([]string{index})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't close index %v: %w", index, err)
+	}
+
+	return nil
+}
+
+func (r *Client) OpenIndex(index string) error {
+	res, err := r.client.Indices.Open([]string{index})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't open index %v: %w", index, err)
+	}
+
+	return nil
+}
+
+// GetIndexSetting returns the value of a setting on an index, given by its flat name (e.g. "index.blocks.write"),
+// or "" if it isn't set
+func (r *Client) GetIndexSetting(index string, setting string) (string, error) {
+	res, err := r.client.Indices.GetSettings(func(req *esapi.IndicesGetSettingsRequest) {
+		req.Index = []string{index}
+		req.Name = []string{setting}
+		req.FlatSettings = util.Boolptr(true)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	body, err := getBodyAndVerifyResponse(res)
+
+	if err != nil {
+		return "", fmt.Errorf("couldn't get setting %v for index %v: %w", setting, index, err)
+	}
+
+	path := fmt.Sprintf("%v.settings.%v", escapePath(index), escapePath(setting))
+
+	return gjson.Get(body, path).String(), nil
+}
+
 func (r *Client) CreateAlias(aliasName string, indexName string) error {
 	res, err := r.client.Indices.PutAlias([]string{indexName}, aliasName)
 
@@ -270,6 +521,20 @@ func (r *Client) CreateAlias(aliasName string, indexName string) error {
 	return nil
 }
 
+func (r *Client) RemoveAlias(aliasName string, indices []string) error {
+	res, err := r.client.Indices.DeleteAlias(indices, []string{aliasName})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't remove alias %v: %w", aliasName, err)
+	}
+
+	return nil
+}
+
 func (r *Client) UpdateAlias(aliasName string, newIndex string, oldIndices []string) error {
 	actions := make([]map[string]interface{}, 0)
 
@@ -384,7 +649,111 @@ func (r *Client) GetTaskStatus(id string) (TaskStatus, error) {
 	return newTaskStatus(body), nil
 }
 
-// Refresh forces a refresh of an index which is useful for refreshing the changelog index during tests
+// GetReindexTasks returns the reindex tasks running into an index, which are found by their description
+func (r *Client) GetReindexTasks(toIndex string) ([]ReindexTask, error) {
+	res, err := r.client.Tasks.List(func(request *esapi.TasksListRequest) {
+		request.Actions = []string{"indices:data/write/reindex"}
+		request.Detailed = util.Boolptr(true)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getBodyAndVerifyResponse(res)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list tasks: %w", err)
+	}
+
+	return newReindexTasks(body, toIndex), nil
+}
+
+// GetIndexHealth waits up to the given timeout for an index to reach at least the given status, returning its
+// health either way
+func (r *Client) GetIndexHealth(index string, waitForStatus string, timeout time.Duration) (IndexHealth, error) {
+	res, err := r.client.Cluster.Health(func(request *esapi.ClusterHealthRequest) {
+		request.Index = []string{index}
+		request.WaitForStatus = waitForStatus
+		request.Timeout = timeout
+	})
+
+	if err != nil {
+		return IndexHealth{}, err
+	}
+
+	body, err := consume(res)
+
+	if err != nil {
+		return IndexHealth{}, err
+	}
+
+	// the health is returned with a timeout status if the index didn't reach the status in time
+	if res.IsError() && res.StatusCode != http.StatusRequestTimeout {
+		return IndexHealth{}, fmt.Errorf("couldn't get health of index %v: HTTP status %v: %v", index,
+			res.StatusCode, body)
+	}
+
+	return newIndexHealth(body), nil
+}
+
+// SearchResponse runs a search request body against an index, returning the response body
+func (r *Client) SearchResponse(indexName string, body string) (string, error) {
+	res, err := r.client.Search(func(request *esapi.SearchRequest) {
+		request.Index = []string{indexName}
+		request.Body = strings.NewReader(body)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	responseBody, err := getBodyAndVerifyResponse(res)
+
+	if err != nil {
+		return "", fmt.Errorf("couldn't search index %v: %w", indexName, err)
+	}
+
+	return responseBody, nil
+}
+
+// Count returns the number of documents in an index or alias
+func (r *Client) Count(index string) (int64, error) {
+	res, err := r.client.Count(func(request *esapi.CountRequest) {
+		request.Index = []string{index}
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := getBodyAndVerifyResponse(res)
+
+	if err != nil {
+		return 0, fmt.Errorf("couldn't count documents in %v: %w", index, err)
+	}
+
+	return gjson.Get(body, "count").Int(), nil
+}
+
+// CancelTask cancels a running task
+func (r *Client) CancelTask(id string) error {
+	res, err := r.client.Tasks.Cancel(func(request *esapi.TasksCancelRequest) {
+		request.TaskID = id
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = verifyResponse(res); err != nil {
+		return fmt.Errorf("couldn't cancel task %v: %w", id, err)
+	}
+
+	return nil
+}
+
+// Refresh forces a refresh of an index, or the indices behind an alias, so searches see every write made to it
 func (r *Client) Refresh(index string) error {
 	res, err := r.client.Indices.Refresh(func(request *esapi.IndicesRefreshRequest) {
 		request.Index = []string{index}
@@ -401,6 +770,10 @@ func (r *Client) Refresh(index string) error {
 	return nil
 }
 
+func escapePath(key string) string {
+	return strings.ReplaceAll(key, ".", "\\.")
+}
+
 func consume(res *esapi.Response) (string, error) {
 	defer func() {
 		_ = res.Body.Close()
@@ -10,6 +10,7 @@ func newDocument(doc gjson.Result) Document {
 			primaryTerm: int(doc.Get("_primary_term").Int()),
 		},
 		source:    doc.Get("_source"),
+		sort:      doc.Get("sort"),
 		isPresent: true,
 	}
 }
@@ -19,9 +20,20 @@ type Document struct {
 	id        string
 	version   Version
 	source    gjson.Result
+	// sort are the document's sort values in search results, if sorted
+	sort gjson.Result
 }
 
 type Version struct {
 	seqNo       int
 	primaryTerm int
 }
+
+func (d Document) IsPresent() bool {
+	return d.isPresent
+}
+
+// Source returns the document source as JSON
+func (d Document) Source() string {
+	return d.source.Raw
+}
@@ -0,0 +1,34 @@
+package es
+
+import (
+	"github.com/hdpe.me/esup/config"
+	"github.com/tidwall/gjson"
+)
+
+func newIndexHealth(body string) IndexHealth {
+	return IndexHealth{
+		Status:             gjson.Get(body, "status").String(),
+		TimedOut:           gjson.Get(body, "timed_out").Bool(),
+		UnassignedShards:   int(gjson.Get(body, "unassigned_shards").Int()),
+		InitializingShards: int(gjson.Get(body, "initializing_shards").Int()),
+		RelocatingShards:   int(gjson.Get(body, "relocating_shards").Int()),
+	}
+}
+
+type IndexHealth struct {
+	Status string
+	// TimedOut is whether the index didn't reach the status waited for in time
+	TimedOut           bool
+	UnassignedShards   int
+	InitializingShards int
+	RelocatingShards   int
+}
+
+// IsAtLeast returns whether the health is as good as the given status
+func (h IndexHealth) IsAtLeast(status string) bool {
+	rank := map[string]int{config.HealthRed: 0, config.HealthYellow: 1, config.HealthGreen: 2}
+
+	got, ok := rank[h.Status]
+
+	return ok && got >= rank[status]
+}
@@ -0,0 +1,24 @@
+package es
+
+import (
+	"github.com/tidwall/gjson"
+	"time"
+)
+
+func newIndexInfo(index gjson.Result) IndexInfo {
+	return IndexInfo{
+		Name:         index.Get("index").String(),
+		Status:       index.Get("status").String(),
+		DocsCount:    index.Get("docs\\.count").Int(),
+		StoreSize:    index.Get("store\\.size").Int(),
+		CreationDate: time.Unix(0, index.Get("creation\\.date").Int()*int64(time.Millisecond)).UTC(),
+	}
+}
+
+type IndexInfo struct {
+	Name         string
+	Status       string
+	DocsCount    int64
+	StoreSize    int64
+	CreationDate time.Time
+}
@@ -0,0 +1,118 @@
+package es
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	JournalStatusRunning  = "RUNNING"
+	JournalStatusComplete = "COMPLETE"
+	JournalStatusUndone   = "UNDONE"
+)
+
+// JournalEntry records the progress of the latest run of a plan in an environment
+type JournalEntry struct {
+	IsPresent bool
+	Status    string
+	// Plan is the plan being executed, as JSON
+	Plan string
+	// Completed is the number of actions in the plan executed successfully
+	Completed int
+	// Done are the positions in the plan of the actions executed successfully, which aren't necessarily the first
+	// Completed when actions are executed concurrently
+	Done []int
+	// Collector is the state collected in executing the plan so far, as JSON
+	Collector string
+	Started   string
+	Timestamp string
+}
+
+func CreateJournalIndex(es *Client, indexName string) error {
+	if err := es.CreateIndex(indexName, `{
+	"mappings": {
+		"properties": {
+			"env_name": {
+				"type": "keyword"
+			},
+			"status": {
+				"type": "keyword"
+			},
+			"plan": {
+				"type": "text",
+				"index": false
+			},
+			"completed": {
+				"type": "integer"
+			},
+			"done": {
+				"type": "integer"
+			},
+			"collector": {
+				"type": "text",
+				"index": false
+			},
+			"started": {
+				"type": "date"
+			},
+			"timestamp": {
+				"type": "date"
+			}
+		}
+	}
+}`); err != nil {
+		return fmt.Errorf("couldn't create journal index: %w", err)
+	}
+
+	return nil
+}
+
+func GetJournalEntry(es *Client, indexName string, envName string) (JournalEntry, error) {
+	res, err := es.GetDocument(indexName, envName)
+
+	if err != nil {
+		return JournalEntry{}, fmt.Errorf("couldn't get journal entry: %w", err)
+	}
+
+	if !res.isPresent {
+		return JournalEntry{}, nil
+	}
+
+	source := res.source
+
+	done := make([]int, 0)
+
+	for _, i := range source.Get("done").Array() {
+		done = append(done, int(i.Int()))
+	}
+
+	return JournalEntry{
+		IsPresent: true,
+		Status:    source.Get("status").String(),
+		Plan:      source.Get("plan").String(),
+		Completed: int(source.Get("completed").Int()),
+		Done:      done,
+		Collector: source.Get("collector").String(),
+		Started:   source.Get("started").String(),
+		Timestamp: source.Get("timestamp").String(),
+	}, nil
+}
+
+func PutJournalEntry(es *Client, indexName string, envName string, entry JournalEntry) error {
+	body := map[string]interface{}{
+		"env_name":  envName,
+		"status":    entry.Status,
+		"plan":      entry.Plan,
+		"completed": entry.Completed,
+		"done":      entry.Done,
+		"collector": entry.Collector,
+		"started":   entry.Started,
+		"timestamp": FormatTimestamp(time.Now()),
+	}
+
+	if err := es.IndexDocument(indexName, envName, body); err != nil {
+		return fmt.Errorf("couldn't put journal entry: %w", err)
+	}
+
+	return nil
+}
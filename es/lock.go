@@ -4,13 +4,64 @@ import (
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/hdpe.me/esup/util"
+	"sort"
 	"time"
 )
 
-var lockDocId = "LOCK"
+// GlobalLockId is the ID of the lock document locking every environment at once. Each environment's own lock
+// document has the environment name as its ID, so no environment can be named this.
+const GlobalLockId = "LOCK"
 
+// maxLocks is the most lock entries GetLocks returns
+const maxLocks = 1000
+
+const (
+	LockStatusLocked   = "LOCKED"
+	LockStatusUnlocked = "UNLOCKED"
+)
+
+// LockEntry is the state of a lock, and while it's held, who holds it
 type LockEntry struct {
 	IsPresent bool
+	LockId    string
+	Status    string
+	ClientId  string
+	EnvName   string
+	Hostname  string
+	Pid       int
+	User      string
+	// Timestamp is when the lock was acquired or released
+	Timestamp string
+	// Expires is when the lease on the lock runs out unless it's renewed, or "" if the lease never runs out
+	Expires string
+	Version Version
+}
+
+func (e LockEntry) IsGlobal() bool {
+	return e.LockId == GlobalLockId
+}
+
+func (e LockEntry) IsLocked() bool {
+	return e.Status == LockStatusLocked
+}
+
+// IsExpired returns whether the lock is held on a lease which has run out
+func (e LockEntry) IsExpired(now time.Time) bool {
+	if !e.IsLocked() || e.Expires == "" {
+		return false
+	}
+
+	expires, err := ParseTimestamp(e.Expires)
+
+	return err == nil && now.After(expires)
+}
+
+// Holder describes who holds the lock
+func (e LockEntry) Holder() string {
+	if e.Hostname == "" {
+		return e.ClientId
+	}
+	return fmt.Sprintf("%v@%v (pid %v)", e.User, e.Hostname, e.Pid)
 }
 
 func CreateLockIndex(es *Client, indexName string) error {
@@ -26,8 +77,20 @@ func CreateLockIndex(es *Client, indexName string) error {
 			"status": {
 				"type": "keyword"
 			},
+			"hostname": {
+				"type": "keyword"
+			},
+			"pid": {
+				"type": "integer"
+			},
+			"user": {
+				"type": "keyword"
+			},
 			"timestamp": {
 				"type": "date"
+			},
+			"expires": {
+				"type": "date"
 			}
 		}
 	}
@@ -35,61 +98,120 @@ func CreateLockIndex(es *Client, indexName string) error {
 		return fmt.Errorf("couldn't create lock index: %w", err)
 	}
 
-	return es.IndexDocument(indexName, lockDocId, map[string]interface{}{
-		"client_id": "",
-		"env_name":  "",
-		"status":    "UNLOCKED",
-		"timestamp": time.Now().UTC().Format(systemTimestampLayout),
-	})
+	return nil
 }
 
-func GetLock(es *Client, indexName string) (Version, error) {
-	res, err := es.GetDocument(indexName, lockDocId)
+// GetLock returns the lock entry with the given ID, which is unlocked if it doesn't exist
+func GetLock(es *Client, indexName string, lockId string) (LockEntry, error) {
+	res, err := es.GetDocument(indexName, lockId)
 
 	if err != nil {
-		return Version{}, fmt.Errorf("couldn't get lock entry: %w", err)
+		return LockEntry{}, fmt.Errorf("couldn't get lock entry: %w", err)
 	}
 
 	if !res.isPresent {
-		return Version{}, fmt.Errorf("couldn't get lock entry: doesn't exist")
+		return LockEntry{LockId: lockId, Status: LockStatusUnlocked}, nil
 	}
 
-	if res.source.Get("status").String() != "UNLOCKED" {
-		return Version{}, fmt.Errorf("changelog is locked")
+	return newLockEntry(res), nil
+}
+
+// GetLocks returns every lock entry, ordered by ID
+func GetLocks(es *Client, indexName string) ([]LockEntry, error) {
+	// a lock entry only just put must be found
+	if err := es.Refresh(indexName); err != nil {
+		return nil, fmt.Errorf("couldn't get lock entries: %w", err)
 	}
 
-	return res.version, nil
+	docs, err := es.Search(indexName, map[string]interface{}{
+		"size":                maxLocks,
+		"seq_no_primary_term": true,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get lock entries: %w", err)
+	}
+
+	entries := make([]LockEntry, 0, len(docs))
+
+	for _, doc := range docs {
+		entries = append(entries, newLockEntry(doc))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LockId < entries[j].LockId
+	})
+
+	return entries, nil
+}
+
+func newLockEntry(doc Document) LockEntry {
+	source := doc.source
+
+	return LockEntry{
+		IsPresent: true,
+		LockId:    doc.id,
+		Status:    source.Get("status").String(),
+		ClientId:  source.Get("client_id").String(),
+		EnvName:   source.Get("env_name").String(),
+		Hostname:  source.Get("hostname").String(),
+		Pid:       int(source.Get("pid").Int()),
+		User:      source.Get("user").String(),
+		Timestamp: source.Get("timestamp").String(),
+		Expires:   source.Get("expires").String(),
+		Version:   doc.version,
+	}
 }
 
-func PutLocked(es *Client, version Version, indexName string, clientId string, envName string) error {
+// PutLocked puts the lock entry with the given ID as held by the given holder, if it hasn't changed since the given
+// version was read
+func PutLocked(es *Client, version Version, indexName string, lockId string, holder LockEntry) error {
 	body := map[string]interface{}{
-		"client_id": clientId,
-		"env_name":  envName,
-		"status":    "LOCKED",
-		"timestamp": time.Now().UTC().Format(systemTimestampLayout),
+		"client_id": holder.ClientId,
+		"env_name":  holder.EnvName,
+		"status":    LockStatusLocked,
+		"hostname":  holder.Hostname,
+		"pid":       holder.Pid,
+		"user":      holder.User,
+		"timestamp": holder.Timestamp,
+		"expires":   holder.Expires,
 	}
 
-	if err := es.IndexDocument(indexName, lockDocId, body, func(request *esapi.IndexRequest) {
-		request.IfSeqNo = util.Intptr(version.seqNo)
-		request.IfPrimaryTerm = util.Intptr(version.primaryTerm)
-	}); err != nil {
+	if err := es.IndexDocument(indexName, lockId, body, ifVersion(version)); err != nil {
 		return fmt.Errorf("couldn't put lock entry: %w", err)
 	}
 
 	return nil
 }
 
-func PutUnlocked(es *Client, indexName string, clientId string, envName string) error {
+// PutUnlocked puts the lock entry with the given ID as released, if it hasn't changed since the given version was
+// read
+func PutUnlocked(es *Client, version Version, indexName string, lockId string, clientId string,
+	envName string) error {
+
 	body := map[string]interface{}{
 		"client_id": clientId,
 		"env_name":  envName,
-		"status":    "UNLOCKED",
-		"timestamp": time.Now().UTC().Format(systemTimestampLayout),
+		"status":    LockStatusUnlocked,
+		"timestamp": FormatTimestamp(time.Now()),
 	}
 
-	if err := es.IndexDocument(indexName, lockDocId, body); err != nil {
+	if err := es.IndexDocument(indexName, lockId, body, ifVersion(version)); err != nil {
 		return fmt.Errorf("couldn't put lock entry: %w", err)
 	}
 
 	return nil
 }
+
+// ifVersion makes indexing a document conditional on its version, or on its not existing for the zero version
+func ifVersion(version Version) func(*esapi.IndexRequest) {
+	return func(request *esapi.IndexRequest) {
+		if version == (Version{}) {
+			request.OpType = "create"
+			return
+		}
+
+		request.IfSeqNo = util.Intptr(version.seqNo)
+		request.IfPrimaryTerm = util.Intptr(version.primaryTerm)
+	}
+}
@@ -1,3 +1,20 @@
 package es
 
-var systemTimestampLayout = "2006-01-02T15:04:05.006"
+import (
+	"time"
+)
+
+// systemTimestampLayout has milliseconds. Earlier versions wrote "2006-01-02T15:04:05.006", whose ".006" is a zero
+// and the two-digit year rather than fractional seconds, so their timestamps parse with the year as under 100
+// milliseconds too many.
+var systemTimestampLayout = "2006-01-02T15:04:05.000"
+
+// FormatTimestamp formats a time as esup writes it to its system indices
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(systemTimestampLayout)
+}
+
+// ParseTimestamp parses a timestamp esup wrote to its system indices
+func ParseTimestamp(timestamp string) (time.Time, error) {
+	return time.Parse(systemTimestampLayout, timestamp)
+}
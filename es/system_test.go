@@ -0,0 +1,48 @@
+package es
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		timestamp string
+		expected  time.Time
+	}{
+		{
+			desc:      "milliseconds",
+			timestamp: "2020-01-02T03:04:05.678",
+			expected:  time.Date(2020, 1, 2, 3, 4, 5, 678*int(time.Millisecond), time.UTC),
+		},
+		{
+			desc:      "legacy year digits read as milliseconds",
+			timestamp: "2020-01-02T03:04:05.020",
+			expected:  time.Date(2020, 1, 2, 3, 4, 5, 20*int(time.Millisecond), time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseTimestamp(tc.timestamp)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(tc.expected) {
+				t.Errorf("got %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 678*int(time.Millisecond), time.FixedZone("x", 3600))
+
+	if got, want := FormatTimestamp(ts), "2020-01-02T02:04:05.678"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -1,19 +1,27 @@
 package es
 
-import "github.com/tidwall/gjson"
+import (
+	"fmt"
+	"github.com/tidwall/gjson"
+	"sort"
+	"strings"
+	"time"
+)
 
 func newTaskStatus(body string) TaskStatus {
 	var completed bool
 	var done int64
+	var created int64
 	var total int64
 	var failure TaskStatusFailure
+	var canceled string
 
 	if parsed := gjson.Get(body, "completed"); parsed.Exists() {
 		completed = parsed.Bool()
 	}
 
 	if parsed := gjson.Get(body, "task.status"); parsed.Exists() {
-		created := parsed.Get("created").Int()
+		created = parsed.Get("created").Int()
 		updated := parsed.Get("updated").Int()
 		deleted := parsed.Get("deleted").Int()
 
@@ -29,19 +37,32 @@ func newTaskStatus(body string) TaskStatus {
 		}
 	}
 
+	// a cancelled task has the reason in its response once completed, and in its status while stopping
+	if parsed := gjson.Get(body, "response.canceled"); parsed.Exists() {
+		canceled = parsed.String()
+	} else if parsed := gjson.Get(body, "task.status.canceled"); parsed.Exists() {
+		canceled = parsed.String()
+	}
+
 	return TaskStatus{
 		IsCompleted: completed,
 		Done:        done,
+		Created:     created,
 		Total:       total,
 		Failure:     failure,
+		Canceled:    canceled,
 	}
 }
 
 type TaskStatus struct {
 	IsCompleted bool
 	Done        int64
-	Total       int64
-	Failure     TaskStatusFailure
+	// Created is how many of the documents done were created rather than updated or deleted
+	Created int64
+	Total   int64
+	Failure TaskStatusFailure
+	// Canceled is the reason the task was cancelled, or "" if it wasn't
+	Canceled string
 }
 
 type TaskStatusFailure struct {
@@ -49,3 +70,37 @@ type TaskStatusFailure struct {
 	CauseType   string
 	CauseReason string
 }
+
+// ReindexTask is a reindex task running in the cluster
+type ReindexTask struct {
+	Id      string
+	Started time.Time
+}
+
+func newReindexTasks(body string, toIndex string) []ReindexTask {
+	result := make([]ReindexTask, 0)
+	destination := fmt.Sprintf(" to [%v]", toIndex)
+
+	gjson.Get(body, "nodes").ForEach(func(_, node gjson.Result) bool {
+		node.Get("tasks").ForEach(func(id, task gjson.Result) bool {
+			// the slices of a sliced reindex are children of the task started
+			if task.Get("parent_task_id").Exists() ||
+				!strings.Contains(task.Get("description").String(), destination) {
+				return true
+			}
+
+			result = append(result, ReindexTask{
+				Id:      id.String(),
+				Started: time.Unix(0, task.Get("start_time_in_millis").Int()*int64(time.Millisecond)),
+			})
+			return true
+		})
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
@@ -8,7 +8,11 @@ import (
 	"github.com/hdpe.me/esup/es"
 	"github.com/hdpe.me/esup/resource"
 	"github.com/hdpe.me/esup/schema"
+	"github.com/hdpe.me/esup/util"
+	"os"
 	"reflect"
+	"sync"
+	"time"
 )
 
 func NewPlanner(es *es.Client, config config.Config, changelog *resource.Changelog, s schema.Schema,
@@ -23,6 +27,8 @@ func NewPlanner(es *es.Client, config config.Config, changelog *resource.Changel
 		envName:   s.EnvName,
 		version:   version,
 		collector: NewCollector(),
+		snapshot:  newSnapshot(),
+		clock:     &util.DefaultClock{},
 	}
 }
 
@@ -35,9 +41,33 @@ type Planner struct {
 	envName   string
 	version   string
 	collector *Collector
+	snapshot  *Snapshot
+	clock     util.Clock
+	prune     bool
 }
 
 func (r *Planner) Plan() ([]PlanAction, error) {
+	plan, err := r.planResources()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.prune {
+		if err = r.appendPruneMutations(&plan); err != nil {
+			return nil, fmt.Errorf("couldn't get prune mutations: %w", err)
+		}
+	}
+
+	if err = r.appendRetentionMutations(&plan); err != nil {
+		return nil, fmt.Errorf("couldn't get retention mutations: %w", err)
+	}
+
+	return plan, nil
+}
+
+// planResources plans the changes to bring the resources in the schema up to date, without housekeeping
+func (r *Planner) planResources() ([]PlanAction, error) {
 	plan := make([]PlanAction, 0)
 
 	if err := r.appendPipelineMutations(&plan); err != nil {
@@ -55,6 +85,17 @@ func (r *Planner) Plan() ([]PlanAction, error) {
 	return plan, nil
 }
 
+// SavedPlan returns a plan along with the cluster state read in making it, so it can be applied later
+func (r *Planner) SavedPlan(plan []PlanAction) SavedPlan {
+	return SavedPlan{
+		Server:   r.config.Server.Address,
+		EnvName:  r.envName,
+		Version:  r.version,
+		Snapshot: *r.snapshot,
+		Actions:  plan,
+	}
+}
+
 func (r *Planner) appendPipelineMutations(plan *[]PlanAction) error {
 
 	for _, p := range r.schema.Pipelines {
@@ -71,23 +112,68 @@ func (r *Planner) appendPipelineMutations(plan *[]PlanAction) error {
 			return fmt.Errorf("couldn't get pipeline %v: %w", pipelineId, err)
 		}
 
+		r.snapshot.recordPipeline(pipelineId, existingPipelineDef)
+
 		changed := true
+		var changes []diff.Change
 
 		if existingPipelineDef != "" {
-			changed, err = diff.Diff(newPipelineDef, existingPipelineDef)
+			changes, err = diff.Changes(newPipelineDef, existingPipelineDef)
 
 			if err != nil {
 				return fmt.Errorf("couldn't diff %v with existing: %w", p.FilePath, err)
 			}
+
+			changed = len(changes) > 0
 		}
 
-		if !changed {
+		// the changelog records the pipelines esup manages, so pruning only ever removes those
+		changelogEntry, err := r.changelog.GetCurrentChangelogEntry("pipeline", p.Name, r.envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entry for %v: %w", p.Name, err)
+		}
+
+		r.snapshot.recordChangelogEntry("pipeline", p.Name, changelogEntry)
+
+		recorded := changelogEntry.IsPresent && !changelogEntry.Deleted && changelogEntry.FinalName == pipelineId
+
+		if recorded {
+			recordedChanges, err := diff.Changes(newPipelineDef, changelogEntry.Content)
+
+			if err != nil {
+				return fmt.Errorf("couldn't diff %v with changelog: %w", p.FilePath, err)
+			}
+
+			recorded = len(recordedChanges) == 0
+		}
+
+		if !changed && recorded {
 			continue
 		}
 
-		*plan = append(*plan, &putPipeline{
-			id:         pipelineId,
-			definition: newPipelineDef,
+		res := Resource{
+			Type:              "pipeline",
+			Identifier:        p.Name,
+			DefinitionChanges: changes,
+		}
+
+		if changed {
+			*plan = append(*plan, &putPipeline{
+				id:         pipelineId,
+				definition: newPipelineDef,
+				resource:   res,
+			})
+		}
+
+		*plan = append(*plan, &writeChangelogEntry{
+			resourceType:       "pipeline",
+			resourceIdentifier: p.Name,
+			finalName:          pipelineId,
+			definition:         newPipelineDef,
+			meta:               "{}",
+			envName:            r.envName,
+			resource:           res,
 		})
 	}
 
@@ -104,6 +190,8 @@ func (r *Planner) appendIndexSetMutations(plan *[]PlanAction) error {
 			return fmt.Errorf("couldn't get alias %v: %w", aliasName, err)
 		}
 
+		r.snapshot.recordAlias(aliasName, existingIndices)
+
 		newIndexDef, err := r.preprocess(is.FilePath)
 
 		if err != nil {
@@ -123,7 +211,9 @@ func (r *Planner) appendIndexSetMutations(plan *[]PlanAction) error {
 			return fmt.Errorf("couldn't get changelog entry for %v: %w", is.ResourceIdentifier(), err)
 		}
 
-		changed, err := changelogDiff(newIndexDef, string(newIndexMeta), changelogEntry)
+		r.snapshot.recordChangelogEntry("index_set", is.ResourceIdentifier(), changelogEntry)
+
+		changed, defChanges, metaChanges, err := changelogDiff(newIndexDef, string(newIndexMeta), changelogEntry)
 
 		if err != nil {
 			return fmt.Errorf("couldn't diff %v with changelog: %w", is.ResourceIdentifier(), err)
@@ -144,38 +234,148 @@ func (r *Planner) appendIndexSetMutations(plan *[]PlanAction) error {
 		}
 
 		pipeline := newPipelineId(is.Meta.Reindex.Pipeline, r.envName)
+		res := Resource{
+			Type:              "index_set",
+			Identifier:        is.ResourceIdentifier(),
+			DefinitionChanges: defChanges,
+			MetaChanges:       metaChanges,
+		}
+
+		// changes to the definition alone might be applied to the existing index without reindexing
+		if !staticIndex && changelogEntry.IsPresent && len(existingIndices) == 1 && len(metaChanges) == 0 &&
+			!planChangesPipeline(*plan, is.Meta.Reindex.Pipeline, r.envName) {
+
+			change, err := classifyIndexChange(changelogEntry.Content, newIndexDef)
+
+			if err != nil {
+				return fmt.Errorf("couldn't classify change to %v: %w", is.ResourceIdentifier(), err)
+			}
+
+			if change.compatible {
+				existingIndex := existingIndices[0]
+
+				if change.mapping != "" {
+					*plan = append(*plan, &putMapping{
+						index:    existingIndex,
+						mapping:  change.mapping,
+						resource: res,
+					})
+				}
+
+				if change.settings != "" {
+					*plan = append(*plan, &putSettings{
+						index:    existingIndex,
+						settings: change.settings,
+						resource: res,
+					})
+				}
+
+				*plan = append(*plan, &writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: is.ResourceIdentifier(),
+					finalName:          existingIndex,
+					definition:         newIndexDef,
+					meta:               string(newIndexMeta),
+					envName:            r.envName,
+					resource:           res,
+				})
+
+				continue
+			}
+		}
 
 		if !staticIndex {
 			*plan = append(*plan, &createIndex{
 				name:       indexName,
 				definition: newIndexDef,
+				resource:   res,
 			})
 		}
 
 		if existingIndices == nil {
 			if !staticIndex {
-				if e := r.config.Prototype.Environment; e != "" && e != r.envName && !is.Meta.Prototype.Disabled {
+				e := r.config.Prototype.Environment
+				fromPrototype := e != "" && e != r.envName && !is.Meta.Prototype.Disabled
+
+				if fromPrototype {
 					*plan = append(*plan, &reindex{
-						from:     newAliasName(is.IndexSet, e),
-						to:       indexName,
-						maxDocs:  is.Meta.Prototype.MaxDocs,
-						pipeline: pipeline,
+						from:              newAliasName(is.IndexSet, e),
+						to:                indexName,
+						maxDocs:           is.Meta.Prototype.MaxDocs,
+						pipeline:          pipeline,
+						requestsPerSecond: is.Meta.Reindex.RequestsPerSecond,
+						slices:            is.Meta.Reindex.Slices,
+						batchSize:         is.Meta.Reindex.BatchSize,
+						resource:          res,
 					})
 				}
+
+				r.appendHealthWait(plan, is, indexName, res)
+
+				if fromPrototype {
+					if err = r.appendVerifications(plan, is, newAliasName(is.IndexSet, e), indexName,
+						is.Meta.Prototype.MaxDocs, res); err != nil {
+						return err
+					}
+				}
 			}
 
 			*plan = append(*plan, &createAlias{
-				name:  aliasName,
-				index: indexName,
+				name:     aliasName,
+				index:    indexName,
+				resource: res,
 			})
 		} else {
 			if !staticIndex {
+				var toBlock []string
+
+				if is.Meta.Reindex.BlockWrites || is.Meta.CatchUp.Field != "" {
+					if toBlock, err = r.unblockedIndices(existingIndices); err != nil {
+						return err
+					}
+				}
+
+				if is.Meta.Reindex.BlockWrites {
+					for _, index := range toBlock {
+						*plan = append(*plan, &blockWrites{
+							index:    index,
+							reason:   fmt.Sprintf("reindexing to %v", indexName),
+							resource: res,
+						})
+					}
+				}
+
 				*plan = append(*plan, &reindex{
-					from:     aliasName,
-					to:       indexName,
-					maxDocs:  -1,
-					pipeline: pipeline,
+					from:              aliasName,
+					to:                indexName,
+					maxDocs:           -1,
+					pipeline:          pipeline,
+					requestsPerSecond: is.Meta.Reindex.RequestsPerSecond,
+					slices:            is.Meta.Reindex.Slices,
+					batchSize:         is.Meta.Reindex.BatchSize,
+					resource:          res,
 				})
+
+				r.appendHealthWait(plan, is, indexName, res)
+
+				if is.Meta.CatchUp.Field != "" {
+					*plan = append(*plan, &catchUp{
+						from:         aliasName,
+						to:           indexName,
+						blockIndices: toBlock,
+						field:        is.Meta.CatchUp.Field,
+						threshold:    is.Meta.CatchUp.Threshold,
+						margin:       is.Meta.CatchUp.Margin,
+						pipeline:     pipeline,
+						slices:       is.Meta.Reindex.Slices,
+						batchSize:    is.Meta.Reindex.BatchSize,
+						resource:     res,
+					})
+				}
+
+				if err = r.appendVerifications(plan, is, aliasName, indexName, -1, res); err != nil {
+					return err
+				}
 			}
 
 			if !staticIndex || !reflect.DeepEqual([]string{indexName}, existingIndices) {
@@ -183,6 +383,7 @@ func (r *Planner) appendIndexSetMutations(plan *[]PlanAction) error {
 					name:            aliasName,
 					indexToAdd:      indexName,
 					indicesToRemove: existingIndices,
+					resource:        res,
 				})
 			}
 		}
@@ -194,12 +395,88 @@ func (r *Planner) appendIndexSetMutations(plan *[]PlanAction) error {
 			definition:         newIndexDef,
 			meta:               string(newIndexMeta),
 			envName:            r.envName,
+			resource:           res,
 		})
 	}
 
 	return nil
 }
 
+// appendVerifications appends the checks a new index reindexed from another must pass before the alias is pointed
+// at it
+func (r *Planner) appendVerifications(plan *[]PlanAction, is schema.IndexSet, from string, indexName string,
+	maxDocs int, res Resource) error {
+
+	if is.Meta.Verify.DocCount {
+		*plan = append(*plan, &verifyDocCount{
+			from:      from,
+			index:     indexName,
+			maxDocs:   maxDocs,
+			tolerance: is.Meta.Verify.Tolerance,
+			resource:  res,
+		})
+	}
+
+	for _, c := range is.Checks {
+		content, err := r.preprocess(c.FilePath)
+
+		if err != nil {
+			return err
+		}
+
+		parsed, err := parseCheck(content)
+
+		if err != nil {
+			return fmt.Errorf("invalid check %v for %v: %w", c.Name, is.ResourceIdentifier(), err)
+		}
+
+		*plan = append(*plan, &runCheck{
+			name:     c.Name,
+			index:    indexName,
+			check:    parsed,
+			resource: res,
+		})
+	}
+
+	return nil
+}
+
+// appendHealthWait appends waiting for a new index to become healthy enough to point the alias at, if required
+// unblockedIndices returns the indices which writes aren't already blocked to. A block already in place isn't a
+// migration's to lift, so it only blocks these.
+func (r *Planner) unblockedIndices(indices []string) ([]string, error) {
+	result := make([]string, 0)
+
+	for _, index := range indices {
+		blocked, err := r.es.GetIndexSetting(index, "index.blocks.write")
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get write block of %v: %w", index, err)
+		}
+
+		r.snapshot.recordWriteBlock(index, blocked)
+
+		if blocked != "true" {
+			result = append(result, index)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Planner) appendHealthWait(plan *[]PlanAction, is schema.IndexSet, indexName string, res Resource) {
+	if is.Meta.Health.Status == "" {
+		return
+	}
+
+	*plan = append(*plan, &waitForHealth{
+		index:    indexName,
+		status:   is.Meta.Health.Status,
+		timeout:  is.Meta.Health.Timeout,
+		resource: res,
+	})
+}
+
 func (r *Planner) appendDocumentMutations(plan *[]PlanAction) error {
 
 	for _, doc := range r.schema.Documents {
@@ -216,6 +493,8 @@ func (r *Planner) appendDocumentMutations(plan *[]PlanAction) error {
 			return fmt.Errorf("couldn't get changelog entry for %v: %w", doc.ResourceIdentifier(), err)
 		}
 
+		r.snapshot.recordChangelogEntry("document", doc.ResourceIdentifier(), changelogEntry)
+
 		docMeta, err := json.Marshal(doc.Meta)
 
 		if err != nil {
@@ -223,7 +502,7 @@ func (r *Planner) appendDocumentMutations(plan *[]PlanAction) error {
 				doc.ResourceIdentifier(), err)
 		}
 
-		changed, err := changelogDiff(final, string(docMeta), changelogEntry)
+		changed, defChanges, metaChanges, err := changelogDiff(final, string(docMeta), changelogEntry)
 
 		if err != nil {
 			return fmt.Errorf("couldn't diff %v with changelog: %w", doc.ResourceIdentifier(), err)
@@ -234,12 +513,19 @@ func (r *Planner) appendDocumentMutations(plan *[]PlanAction) error {
 		}
 
 		index := newAliasName(doc.IndexSet, r.envName)
+		res := Resource{
+			Type:              "document",
+			Identifier:        doc.ResourceIdentifier(),
+			DefinitionChanges: defChanges,
+			MetaChanges:       metaChanges,
+		}
 
 		if !doc.Meta.Ignored {
 			*plan = append(*plan, &indexDocument{
 				id:       doc.Name,
 				index:    index,
 				document: final,
+				resource: res,
 			})
 		}
 
@@ -250,6 +536,7 @@ func (r *Planner) appendDocumentMutations(plan *[]PlanAction) error {
 			definition:         final,
 			meta:               string(docMeta),
 			envName:            r.envName,
+			resource:           res,
 		})
 	}
 
@@ -282,28 +569,28 @@ func newPipelineId(name string, envName string) string {
 	return fmt.Sprintf("%v-%v", envName, name)
 }
 
-func changelogDiff(newResourceDef string, newResourceMeta string, changelogEntry es.ChangelogEntry) (bool, error) {
+// changelogDiff returns whether a resource has changed since its changelog entry and, if it was present, the
+// changes to its definition and meta
+func changelogDiff(newResourceDef string, newResourceMeta string,
+	changelogEntry es.ChangelogEntry) (bool, []diff.Change, []diff.Change, error) {
+
 	if !changelogEntry.IsPresent {
-		return true, nil
+		return true, nil, nil, nil
 	}
 
-	changed, err := diff.Diff(newResourceDef, changelogEntry.Content)
+	defChanges, err := diff.Changes(newResourceDef, changelogEntry.Content)
 
 	if err != nil {
-		return false, fmt.Errorf("couldn't diff resource content with existing: %w", err)
+		return false, nil, nil, fmt.Errorf("couldn't diff resource content with existing: %w", err)
 	}
 
-	if changed {
-		return true, nil
-	}
-
-	changed, err = diff.Diff(newResourceMeta, changelogEntry.Meta)
+	metaChanges, err := diff.Changes(newResourceMeta, changelogEntry.Meta)
 
 	if err != nil {
-		return false, fmt.Errorf("couldn't diff resource meta with existing: %w", err)
+		return false, nil, nil, fmt.Errorf("couldn't diff resource meta with existing: %w", err)
 	}
 
-	return changed, nil
+	return len(defChanges) > 0 || len(metaChanges) > 0, defChanges, metaChanges, nil
 }
 
 func planChangesPipeline(plan []PlanAction, pipeline string, envName string) bool {
@@ -321,16 +608,207 @@ func planChangesPipeline(plan []PlanAction, pipeline string, envName string) boo
 type PlanAction interface {
 	Execute(es *es.Client, changelog *resource.Changelog, collector *Collector) error
 	String() string
+	Resource() Resource
+}
+
+// Resource identifies the schema resource a plan action was planned for, and how it has changed
+type Resource struct {
+	Type              string        `json:"type"`
+	Identifier        string        `json:"identifier"`
+	DefinitionChanges []diff.Change `json:"definitionChanges,omitempty"`
+	MetaChanges       []diff.Change `json:"metaChanges,omitempty"`
 }
 
+// Collector records the changes made in executing a plan, so they can be undone if it fails, or resumed if it's
+// interrupted
 type Collector struct {
 	Indices   []string
 	Pipelines []string
+	// PreviousPipelines are the definitions of Pipelines before they were put, or "" for new pipelines
+	PreviousPipelines map[string]string
+	// ReindexTasks are the IDs of the reindex tasks started, by destination index
+	ReindexTasks map[string]string
+	// ReindexesStarted are when the reindex tasks in ReindexTasks were started, by destination index
+	ReindexesStarted map[string]time.Time
+	// ReindexedDocs are how many documents were reindexed into each destination index: the total of its reindex task,
+	// plus those created by any catch-up passes
+	ReindexedDocs map[string]int64
+	// BlockedIndices are the indices to which writes were blocked
+	BlockedIndices []string
+	// PendingIndices are the indices whose creation was interrupted, which may or may not have been created
+	PendingIndices []string
+	// PendingReindexes are the destination indices of reindex requests which were interrupted, and may or may not
+	// have started a task
+	PendingReindexes []string
+	// OnReindexStarted, if set, is called with the ID of each reindex task as soon as it starts
+	OnReindexStarted func(taskId string) error `json:"-"`
+
+	// mu guards the collector against actions for different index sets executing concurrently
+	mu       sync.Mutex
+	progress *progress
+}
+
+func (c *Collector) addIndex(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Indices = append(c.Indices, name)
+}
+
+func (c *Collector) addPipeline(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Pipelines = append(c.Pipelines, id)
+}
+
+func (c *Collector) previousPipeline(id string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous, ok := c.PreviousPipelines[id]
+	return previous, ok
+}
+
+func (c *Collector) setPreviousPipeline(id string, previous string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PreviousPipelines[id] = previous
+}
+
+func (c *Collector) reindexTask(to string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	taskId, ok := c.ReindexTasks[to]
+	return taskId, ok
+}
+
+func (c *Collector) setReindexTask(to string, taskId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ReindexTasks[to] = taskId
+}
+
+func (c *Collector) reindexStarted(to string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	started, ok := c.ReindexesStarted[to]
+	return started, ok
+}
+
+func (c *Collector) setReindexStarted(to string, started time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ReindexesStarted[to] = started
+}
+
+func (c *Collector) reindexedDocs(to string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	docs, ok := c.ReindexedDocs[to]
+	return docs, ok
+}
+
+// setReindexedDocs sets how many documents the reindex task to the destination index copied, or adds to it if add
+func (c *Collector) setReindexedDocs(to string, docs int64, add bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ReindexedDocs == nil {
+		// a run recorded in the journal before the field was added
+		c.ReindexedDocs = map[string]int64{}
+	}
+
+	if add {
+		docs += c.ReindexedDocs[to]
+	}
+
+	c.ReindexedDocs[to] = docs
+}
+
+func (c *Collector) addBlockedIndex(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a resumed run blocks the same index again
+	if !containsString(c.BlockedIndices, name) {
+		c.BlockedIndices = append(c.BlockedIndices, name)
+	}
+}
+
+func (c *Collector) addPendingIndex(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PendingIndices = append(c.PendingIndices, name)
+}
+
+func (c *Collector) isPendingIndex(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return containsString(c.PendingIndices, name)
+}
+
+func (c *Collector) addPendingReindex(to string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PendingReindexes = append(c.PendingReindexes, to)
+}
+
+func (c *Collector) isPendingReindex(to string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return containsString(c.PendingReindexes, to)
+}
+
+func (c *Collector) marshal() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return json.Marshal(c)
+}
+
+// progressDisplay returns the display on which actions show their progress
+func (c *Collector) progressDisplay() *progress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.progress == nil {
+		c.progress = newProgress(os.Stderr)
+	}
+
+	return c.progress
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 func NewCollector() *Collector {
 	return &Collector{
-		Indices:   []string{},
-		Pipelines: []string{},
+		Indices:           []string{},
+		Pipelines:         []string{},
+		PreviousPipelines: map[string]string{},
+		ReindexTasks:      map[string]string{},
+		ReindexesStarted:  map[string]time.Time{},
+		ReindexedDocs:     map[string]int64{},
+		BlockedIndices:    []string{},
+		PendingIndices:    []string{},
+		PendingReindexes:  []string{},
 	}
 }
@@ -1,27 +1,46 @@
 package plan
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/cheggaaa/pb/v3"
 	"github.com/hdpe.me/esup/es"
 	"github.com/hdpe.me/esup/resource"
 	"strings"
-	"sync"
 	"time"
 )
 
 type createIndex struct {
 	name       string
 	definition string
+	resource   Resource
 }
 
 func (r *createIndex) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	// an interrupted run may have created the index without knowing it
+	if collector.isPendingIndex(r.name) {
+		indices, err := es.GetIndices(r.name)
+
+		if err != nil {
+			return err
+		}
+
+		if len(indices) > 0 {
+			collector.addIndex(r.name)
+			return nil
+		}
+	}
+
 	if err := es.CreateIndex(r.name, r.definition); err != nil {
+		// the request may have reached Elasticsearch before it was cancelled, so the index is looked for if the
+		// run is undone or resumed
+		if es.Context().Err() != nil {
+			collector.addPendingIndex(r.name)
+		}
 		return err
 	}
 
-	collector.Indices = append(collector.Indices, r.name)
+	collector.addIndex(r.name)
 
 	return nil
 }
@@ -30,6 +49,37 @@ func (r *createIndex) String() string {
 	return fmt.Sprintf("create index %v", r.name)
 }
 
+func (r *createIndex) Resource() Resource {
+	return r.resource
+}
+
+type createIndexFields struct {
+	Index      string `json:"index"`
+	Definition string `json:"definition"`
+}
+
+func (r *createIndex) MarshalJSON() ([]byte, error) {
+	return marshalAction("createIndex", r.resource, createIndexFields{r.name, r.definition})
+}
+
+func (r *createIndex) UnmarshalJSON(data []byte) error {
+	var f createIndexFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = createIndex{
+		name:       f.Index,
+		definition: f.Definition,
+		resource:   res,
+	}
+
+	return nil
+}
+
 type writeChangelogEntry struct {
 	resourceType       string
 	resourceIdentifier string
@@ -37,6 +87,7 @@ type writeChangelogEntry struct {
 	definition         string
 	meta               string
 	envName            string
+	resource           Resource
 }
 
 func (r *writeChangelogEntry) Execute(_ *es.Client, changelog *resource.Changelog, _ *Collector) error {
@@ -48,70 +99,176 @@ func (r *writeChangelogEntry) String() string {
 	return fmt.Sprintf("write %v changelog entry for %v:%v", r.resourceType, r.envName, r.resourceIdentifier)
 }
 
+func (r *writeChangelogEntry) Resource() Resource {
+	return r.resource
+}
+
+type writeChangelogEntryFields struct {
+	ResourceType       string `json:"resourceType"`
+	ResourceIdentifier string `json:"resourceIdentifier"`
+	FinalName          string `json:"finalName"`
+	Definition         string `json:"definition"`
+	Meta               string `json:"meta"`
+	EnvName            string `json:"envName"`
+}
+
+func (r *writeChangelogEntry) MarshalJSON() ([]byte, error) {
+	return marshalAction("writeChangelogEntry", r.resource, writeChangelogEntryFields{r.resourceType,
+		r.resourceIdentifier, r.finalName, r.definition, r.meta, r.envName})
+}
+
+func (r *writeChangelogEntry) UnmarshalJSON(data []byte) error {
+	var f writeChangelogEntryFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = writeChangelogEntry{
+		resourceType:       f.ResourceType,
+		resourceIdentifier: f.ResourceIdentifier,
+		finalName:          f.FinalName,
+		definition:         f.Definition,
+		meta:               f.Meta,
+		envName:            f.EnvName,
+		resource:           res,
+	}
+
+	return nil
+}
+
 type reindex struct {
-	from     string
-	to       string
-	maxDocs  int
-	pipeline string
+	from              string
+	to                string
+	maxDocs           int
+	pipeline          string
+	requestsPerSecond int
+	slices            string
+	batchSize         int
+	resource          Resource
 }
 
-func (r *reindex) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
-	taskId, err := es.Reindex(r.from, r.to, r.maxDocs, r.pipeline)
+func (r *reindex) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	taskId, err := r.startOrReattach(es, collector)
+
+	if err != nil {
+		return err
+	}
+
+	status, err := waitForReindex(es, collector, taskId, r.to)
 
 	if err != nil {
 		return err
 	}
 
+	collector.setReindexedDocs(r.to, status.Total, false)
+
+	return nil
+}
+
+// waitForReindex shows the progress of a reindex task until it completes, returning its final status
+func waitForReindex(es *es.Client, collector *Collector, taskId string, label string) (status es.TaskStatus,
+	err error) {
+
 	ticker := time.NewTicker(1 * time.Second)
 	defer func() {
 		ticker.Stop()
 	}()
 
-	result := make(chan error, 1)
-	var final error
+	progress := collector.progressDisplay()
+	bar := progress.add(fmt.Sprintf("%v (task %v)", label, taskId))
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	// the context is cancelled when the migration is interrupted, in which case the task is left running
+	// so the run can be resumed or undone
+	ctx := es.Context()
 
-	var progress *pb.ProgressBar
+	for {
+		select {
+		case <-ctx.Done():
+			progress.finish(bar)
+			return status, fmt.Errorf("stopped waiting for task %v: %w", taskId, ctx.Err())
+		case <-ticker.C:
+			status, err = es.GetTaskStatus(taskId)
 
-	done := func(err error) {
-		result <- err
-		close(result)
-	}
+			if err != nil {
+				progress.finish(bar)
+				return status, err
+			}
+
+			progress.update(bar, status.Done, status.Total)
 
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				status, err := es.GetTaskStatus(taskId)
+			if status.IsCompleted {
+				progress.finish(bar)
 
-				if progress == nil {
-					progress = pb.Start64(status.Total)
+				if failure := status.Failure; failure.CauseType != "" {
+					return status, fmt.Errorf("%v: [%v] %v", failure.Id, failure.CauseType, failure.CauseReason)
 				}
-				progress.SetCurrent(status.Done)
-
-				if err != nil {
-					done(err)
-				} else if status.IsCompleted {
-					if failure := status.Failure; failure.CauseType != "" {
-						done(fmt.Errorf("%v: [%v] %v", failure.Id, failure.CauseType, failure.CauseReason))
-					} else {
-						done(nil)
-					}
+				if status.Canceled != "" {
+					return status, fmt.Errorf("task %v was cancelled: %v", taskId, status.Canceled)
 				}
-			case e := <-result:
-				final = e
-				progress.Finish()
-				wg.Done()
-				return
+				return status, nil
 			}
 		}
-	}()
+	}
+}
+
+// startOrReattach returns the ID of the task reindexing to the destination index in an interrupted run, if
+// Elasticsearch still knows it and it hasn't failed or been cancelled, or else starts a new reindex task
+func (r *reindex) startOrReattach(es *es.Client, collector *Collector) (string, error) {
+	if taskId, ok := collector.reindexTask(r.to); ok {
+		status, err := es.GetTaskStatus(taskId)
+
+		if err == nil && status.Failure.CauseType == "" && status.Canceled == "" {
+			return taskId, nil
+		}
+	} else if collector.isPendingReindex(r.to) {
+		tasks, err := es.GetReindexTasks(r.to)
+
+		if err != nil {
+			return "", err
+		}
+
+		if len(tasks) > 0 {
+			collector.setReindexTask(r.to, tasks[0].Id)
+			collector.setReindexStarted(r.to, tasks[0].Started)
+			return tasks[0].Id, nil
+		}
+	}
+
+	started := time.Now()
+	taskId, err := es.Reindex(r.from, r.to, r.options())
+
+	if err != nil {
+		// the request may have started a task before it was cancelled, so tasks are looked for by destination if
+		// the run is undone or resumed
+		if es.Context().Err() != nil {
+			collector.addPendingReindex(r.to)
+		}
+		return "", err
+	}
+
+	collector.setReindexTask(r.to, taskId)
+	collector.setReindexStarted(r.to, started)
+
+	if collector.OnReindexStarted != nil {
+		if err = collector.OnReindexStarted(taskId); err != nil {
+			return "", err
+		}
+	}
 
-	wg.Wait()
+	return taskId, nil
+}
 
-	return final
+func (r *reindex) options() es.ReindexOptions {
+	return es.ReindexOptions{
+		MaxDocs:           r.maxDocs,
+		Pipeline:          r.pipeline,
+		RequestsPerSecond: r.requestsPerSecond,
+		Slices:            r.slices,
+		BatchSize:         r.batchSize,
+	}
 }
 
 func (r *reindex) String() string {
@@ -122,12 +279,64 @@ func (r *reindex) String() string {
 	if r.maxDocs != -1 {
 		s = fmt.Sprintf("%v (%v max docs)", s, r.maxDocs)
 	}
+	if r.requestsPerSecond > 0 {
+		s = fmt.Sprintf("%v (%v requests/s)", s, r.requestsPerSecond)
+	}
+	if r.slices != "" {
+		s = fmt.Sprintf("%v (%v slices)", s, r.slices)
+	}
+	if r.batchSize > 0 {
+		s = fmt.Sprintf("%v (batches of %v)", s, r.batchSize)
+	}
 	return s
 }
 
+func (r *reindex) Resource() Resource {
+	return r.resource
+}
+
+type reindexFields struct {
+	From              string `json:"from"`
+	To                string `json:"to"`
+	MaxDocs           int    `json:"maxDocs"`
+	Pipeline          string `json:"pipeline"`
+	RequestsPerSecond int    `json:"requestsPerSecond,omitempty"`
+	Slices            string `json:"slices,omitempty"`
+	BatchSize         int    `json:"batchSize,omitempty"`
+}
+
+func (r *reindex) MarshalJSON() ([]byte, error) {
+	return marshalAction("reindex", r.resource, reindexFields{r.from, r.to, r.maxDocs, r.pipeline,
+		r.requestsPerSecond, r.slices, r.batchSize})
+}
+
+func (r *reindex) UnmarshalJSON(data []byte) error {
+	var f reindexFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = reindex{
+		from:              f.From,
+		to:                f.To,
+		maxDocs:           f.MaxDocs,
+		pipeline:          f.Pipeline,
+		requestsPerSecond: f.RequestsPerSecond,
+		slices:            f.Slices,
+		batchSize:         f.BatchSize,
+		resource:          res,
+	}
+
+	return nil
+}
+
 type createAlias struct {
-	name  string
-	index string
+	name     string
+	index    string
+	resource Resource
 }
 
 func (r *createAlias) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
@@ -138,10 +347,42 @@ func (r *createAlias) String() string {
 	return fmt.Sprintf("create alias %v", aliasString(r.name, r.index))
 }
 
+func (r *createAlias) Resource() Resource {
+	return r.resource
+}
+
+type createAliasFields struct {
+	Alias string `json:"alias"`
+	Index string `json:"index"`
+}
+
+func (r *createAlias) MarshalJSON() ([]byte, error) {
+	return marshalAction("createAlias", r.resource, createAliasFields{r.name, r.index})
+}
+
+func (r *createAlias) UnmarshalJSON(data []byte) error {
+	var f createAliasFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = createAlias{
+		name:     f.Alias,
+		index:    f.Index,
+		resource: res,
+	}
+
+	return nil
+}
+
 type updateAlias struct {
 	name            string
 	indexToAdd      string
 	indicesToRemove []string
+	resource        Resource
 }
 
 func (r *updateAlias) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
@@ -160,21 +401,155 @@ func (r *updateAlias) String() string {
 	return str
 }
 
+func (r *updateAlias) Resource() Resource {
+	return r.resource
+}
+
+type updateAliasFields struct {
+	Alias           string   `json:"alias"`
+	IndexToAdd      string   `json:"indexToAdd"`
+	IndicesToRemove []string `json:"indicesToRemove"`
+}
+
+func (r *updateAlias) MarshalJSON() ([]byte, error) {
+	return marshalAction("updateAlias", r.resource, updateAliasFields{r.name, r.indexToAdd, r.indicesToRemove})
+}
+
+func (r *updateAlias) UnmarshalJSON(data []byte) error {
+	var f updateAliasFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = updateAlias{
+		name:            f.Alias,
+		indexToAdd:      f.IndexToAdd,
+		indicesToRemove: f.IndicesToRemove,
+		resource:        res,
+	}
+
+	return nil
+}
+
 func aliasString(aliasName string, indexName string) string {
 	return fmt.Sprintf("%v -> %v", aliasName, indexName)
 }
 
+type putMapping struct {
+	index    string
+	mapping  string
+	resource Resource
+}
+
+func (r *putMapping) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.PutMapping(r.index, r.mapping)
+}
+
+func (r *putMapping) String() string {
+	return fmt.Sprintf("put mapping for index %v", r.index)
+}
+
+func (r *putMapping) Resource() Resource {
+	return r.resource
+}
+
+type putMappingFields struct {
+	Index   string `json:"index"`
+	Mapping string `json:"mapping"`
+}
+
+func (r *putMapping) MarshalJSON() ([]byte, error) {
+	return marshalAction("putMapping", r.resource, putMappingFields{r.index, r.mapping})
+}
+
+func (r *putMapping) UnmarshalJSON(data []byte) error {
+	var f putMappingFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = putMapping{
+		index:    f.Index,
+		mapping:  f.Mapping,
+		resource: res,
+	}
+
+	return nil
+}
+
+type putSettings struct {
+	index    string
+	settings string
+	resource Resource
+}
+
+func (r *putSettings) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.PutSettings(r.index, r.settings)
+}
+
+func (r *putSettings) String() string {
+	return fmt.Sprintf("put settings for index %v: %v", r.index, r.settings)
+}
+
+func (r *putSettings) Resource() Resource {
+	return r.resource
+}
+
+type putSettingsFields struct {
+	Index    string `json:"index"`
+	Settings string `json:"settings"`
+}
+
+func (r *putSettings) MarshalJSON() ([]byte, error) {
+	return marshalAction("putSettings", r.resource, putSettingsFields{r.index, r.settings})
+}
+
+func (r *putSettings) UnmarshalJSON(data []byte) error {
+	var f putSettingsFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = putSettings{
+		index:    f.Index,
+		settings: f.Settings,
+		resource: res,
+	}
+
+	return nil
+}
+
 type putPipeline struct {
 	id         string
 	definition string
+	resource   Resource
 }
 
 func (r *putPipeline) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	if _, ok := collector.previousPipeline(r.id); !ok {
+		previous, err := es.GetPipelineDef(r.id)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get pipeline %v: %w", r.id, err)
+		}
+
+		collector.setPreviousPipeline(r.id, previous)
+	}
+
 	if err := es.PutPipelineDef(r.id, r.definition); err != nil {
 		return err
 	}
 
-	collector.Pipelines = append(collector.Pipelines, r.id)
+	collector.addPipeline(r.id)
 
 	return nil
 }
@@ -183,10 +558,42 @@ func (r *putPipeline) String() string {
 	return fmt.Sprintf("put pipeline %v", r.id)
 }
 
+func (r *putPipeline) Resource() Resource {
+	return r.resource
+}
+
+type putPipelineFields struct {
+	Pipeline   string `json:"pipeline"`
+	Definition string `json:"definition"`
+}
+
+func (r *putPipeline) MarshalJSON() ([]byte, error) {
+	return marshalAction("putPipeline", r.resource, putPipelineFields{r.id, r.definition})
+}
+
+func (r *putPipeline) UnmarshalJSON(data []byte) error {
+	var f putPipelineFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = putPipeline{
+		id:         f.Pipeline,
+		definition: f.Definition,
+		resource:   res,
+	}
+
+	return nil
+}
+
 type indexDocument struct {
 	index    string
 	id       string
 	document string
+	resource Resource
 }
 
 func (r *indexDocument) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
@@ -206,3 +613,894 @@ func (r *indexDocument) Execute(es *es.Client, _ *resource.Changelog, _ *Collect
 func (r *indexDocument) String() string {
 	return fmt.Sprintf("index document %v/%v", r.index, r.id)
 }
+
+func (r *indexDocument) Resource() Resource {
+	return r.resource
+}
+
+type indexDocumentFields struct {
+	Index    string `json:"index"`
+	Id       string `json:"id"`
+	Document string `json:"document"`
+}
+
+func (r *indexDocument) MarshalJSON() ([]byte, error) {
+	return marshalAction("indexDocument", r.resource, indexDocumentFields{r.index, r.id, r.document})
+}
+
+func (r *indexDocument) UnmarshalJSON(data []byte) error {
+	var f indexDocumentFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = indexDocument{
+		index:    f.Index,
+		id:       f.Id,
+		document: f.Document,
+		resource: res,
+	}
+
+	return nil
+}
+
+type closeIndex struct {
+	name     string
+	reason   string
+	resource Resource
+}
+
+func (r *closeIndex) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.CloseIndex(r.name)
+}
+
+func (r *closeIndex) String() string {
+	return fmt.Sprintf("close index %v (%v)", r.name, r.reason)
+}
+
+func (r *closeIndex) Resource() Resource {
+	return r.resource
+}
+
+type closeIndexFields struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+func (r *closeIndex) MarshalJSON() ([]byte, error) {
+	return marshalAction("closeIndex", r.resource, closeIndexFields{r.name, r.reason})
+}
+
+func (r *closeIndex) UnmarshalJSON(data []byte) error {
+	var f closeIndexFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = closeIndex{
+		name:     f.Index,
+		reason:   f.Reason,
+		resource: res,
+	}
+
+	return nil
+}
+
+type openIndex struct {
+	name     string
+	reason   string
+	resource Resource
+}
+
+func (r *openIndex) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.OpenIndex(r.name)
+}
+
+func (r *openIndex) String() string {
+	return fmt.Sprintf("open index %v (%v)", r.name, r.reason)
+}
+
+func (r *openIndex) Resource() Resource {
+	return r.resource
+}
+
+type openIndexFields struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+func (r *openIndex) MarshalJSON() ([]byte, error) {
+	return marshalAction("openIndex", r.resource, openIndexFields{r.name, r.reason})
+}
+
+func (r *openIndex) UnmarshalJSON(data []byte) error {
+	var f openIndexFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = openIndex{
+		name:     f.Index,
+		reason:   f.Reason,
+		resource: res,
+	}
+
+	return nil
+}
+
+type blockWrites struct {
+	index    string
+	reason   string
+	resource Resource
+}
+
+// Execute records the index as blocked before blocking it, so the block is lifted on undo even if the request is
+// interrupted in flight. The planner leaves out indices already blocked.
+func (r *blockWrites) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	collector.addBlockedIndex(r.index)
+
+	return es.PutSettings(r.index, `{"index.blocks.write":true}`)
+}
+
+func (r *blockWrites) String() string {
+	return fmt.Sprintf("block writes to index %v (%v)", r.index, r.reason)
+}
+
+func (r *blockWrites) Resource() Resource {
+	return r.resource
+}
+
+type blockWritesFields struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+func (r *blockWrites) MarshalJSON() ([]byte, error) {
+	return marshalAction("blockWrites", r.resource, blockWritesFields{r.index, r.reason})
+}
+
+func (r *blockWrites) UnmarshalJSON(data []byte) error {
+	var f blockWritesFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = blockWrites{
+		index:    f.Index,
+		reason:   f.Reason,
+		resource: res,
+	}
+
+	return nil
+}
+
+type unblockWrites struct {
+	index    string
+	reason   string
+	resource Resource
+}
+
+func (r *unblockWrites) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.PutSettings(r.index, `{"index.blocks.write":null}`)
+}
+
+func (r *unblockWrites) String() string {
+	return fmt.Sprintf("lift write block on index %v (%v)", r.index, r.reason)
+}
+
+func (r *unblockWrites) Resource() Resource {
+	return r.resource
+}
+
+type unblockWritesFields struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+func (r *unblockWrites) MarshalJSON() ([]byte, error) {
+	return marshalAction("unblockWrites", r.resource, unblockWritesFields{r.index, r.reason})
+}
+
+func (r *unblockWrites) UnmarshalJSON(data []byte) error {
+	var f unblockWritesFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = unblockWrites{
+		index:    f.Index,
+		reason:   f.Reason,
+		resource: res,
+	}
+
+	return nil
+}
+
+type deleteIndex struct {
+	name     string
+	reason   string
+	resource Resource
+}
+
+func (r *deleteIndex) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.DeleteIndex(r.name)
+}
+
+func (r *deleteIndex) String() string {
+	return fmt.Sprintf("delete index %v (%v)", r.name, r.reason)
+}
+
+func (r *deleteIndex) Resource() Resource {
+	return r.resource
+}
+
+type deleteIndexFields struct {
+	Index  string `json:"index"`
+	Reason string `json:"reason"`
+}
+
+func (r *deleteIndex) MarshalJSON() ([]byte, error) {
+	return marshalAction("deleteIndex", r.resource, deleteIndexFields{r.name, r.reason})
+}
+
+func (r *deleteIndex) UnmarshalJSON(data []byte) error {
+	var f deleteIndexFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = deleteIndex{
+		name:     f.Index,
+		reason:   f.Reason,
+		resource: res,
+	}
+
+	return nil
+}
+
+type deletePipeline struct {
+	id       string
+	resource Resource
+}
+
+func (r *deletePipeline) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.DeletePipeline(r.id)
+}
+
+func (r *deletePipeline) String() string {
+	return fmt.Sprintf("delete pipeline %v", r.id)
+}
+
+func (r *deletePipeline) Resource() Resource {
+	return r.resource
+}
+
+type deletePipelineFields struct {
+	Id string `json:"id"`
+}
+
+func (r *deletePipeline) MarshalJSON() ([]byte, error) {
+	return marshalAction("deletePipeline", r.resource, deletePipelineFields{r.id})
+}
+
+func (r *deletePipeline) UnmarshalJSON(data []byte) error {
+	var f deletePipelineFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = deletePipeline{
+		id:       f.Id,
+		resource: res,
+	}
+
+	return nil
+}
+
+type deleteDocument struct {
+	index    string
+	id       string
+	resource Resource
+}
+
+func (r *deleteDocument) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.DeleteDocument(r.index, r.id)
+}
+
+func (r *deleteDocument) String() string {
+	return fmt.Sprintf("delete document %v/%v", r.index, r.id)
+}
+
+func (r *deleteDocument) Resource() Resource {
+	return r.resource
+}
+
+type deleteDocumentFields struct {
+	Index string `json:"index"`
+	Id    string `json:"id"`
+}
+
+func (r *deleteDocument) MarshalJSON() ([]byte, error) {
+	return marshalAction("deleteDocument", r.resource, deleteDocumentFields{r.index, r.id})
+}
+
+func (r *deleteDocument) UnmarshalJSON(data []byte) error {
+	var f deleteDocumentFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = deleteDocument{
+		index:    f.Index,
+		id:       f.Id,
+		resource: res,
+	}
+
+	return nil
+}
+
+type removeAlias struct {
+	name     string
+	indices  []string
+	resource Resource
+}
+
+func (r *removeAlias) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	return es.RemoveAlias(r.name, r.indices)
+}
+
+func (r *removeAlias) String() string {
+	removes := make([]string, 0)
+	for _, index := range r.indices {
+		removes = append(removes, aliasString(r.name, index))
+	}
+	return fmt.Sprintf("remove alias %v", strings.Join(removes, ", "))
+}
+
+func (r *removeAlias) Resource() Resource {
+	return r.resource
+}
+
+type removeAliasFields struct {
+	Alias   string   `json:"alias"`
+	Indices []string `json:"indices"`
+}
+
+func (r *removeAlias) MarshalJSON() ([]byte, error) {
+	return marshalAction("removeAlias", r.resource, removeAliasFields{r.name, r.indices})
+}
+
+func (r *removeAlias) UnmarshalJSON(data []byte) error {
+	var f removeAliasFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = removeAlias{
+		name:     f.Alias,
+		indices:  f.Indices,
+		resource: res,
+	}
+
+	return nil
+}
+
+type writeTombstone struct {
+	resourceType       string
+	resourceIdentifier string
+	envName            string
+	resource           Resource
+}
+
+func (r *writeTombstone) Execute(_ *es.Client, changelog *resource.Changelog, _ *Collector) error {
+	return changelog.PutChangelogEntry(r.resourceType, r.resourceIdentifier, "",
+		es.ChangelogEntry{Deleted: true}, r.envName)
+}
+
+func (r *writeTombstone) String() string {
+	return fmt.Sprintf("write %v tombstone changelog entry for %v:%v", r.resourceType, r.envName,
+		r.resourceIdentifier)
+}
+
+func (r *writeTombstone) Resource() Resource {
+	return r.resource
+}
+
+type writeTombstoneFields struct {
+	ResourceType       string `json:"resourceType"`
+	ResourceIdentifier string `json:"resourceIdentifier"`
+	EnvName            string `json:"envName"`
+}
+
+func (r *writeTombstone) MarshalJSON() ([]byte, error) {
+	return marshalAction("writeTombstone", r.resource, writeTombstoneFields{r.resourceType, r.resourceIdentifier,
+		r.envName})
+}
+
+func (r *writeTombstone) UnmarshalJSON(data []byte) error {
+	var f writeTombstoneFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = writeTombstone{
+		resourceType:       f.ResourceType,
+		resourceIdentifier: f.ResourceIdentifier,
+		envName:            f.EnvName,
+		resource:           res,
+	}
+
+	return nil
+}
+
+type verifyDocCount struct {
+	from      string
+	index     string
+	maxDocs   int
+	tolerance float64
+	resource  Resource
+}
+
+// Execute compares the documents in the new index with how many were reindexed into it, rather than with the source,
+// which may have been written to since
+func (r *verifyDocCount) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	if err := es.Refresh(r.index); err != nil {
+		return err
+	}
+
+	want, ok := collector.reindexedDocs(r.index)
+
+	if !ok {
+		return fmt.Errorf("couldn't find how many documents were reindexed into %v", r.index)
+	}
+
+	if r.maxDocs != -1 && want > int64(r.maxDocs) {
+		want = int64(r.maxDocs)
+	}
+
+	got, err := es.Count(r.index)
+
+	if err != nil {
+		return err
+	}
+
+	if !withinTolerance(got, want, r.tolerance) {
+		return fmt.Errorf("index %v has %v document(s), want %v from %v (tolerance %v%%)", r.index, got, want,
+			r.from, r.tolerance)
+	}
+
+	return nil
+}
+
+func (r *verifyDocCount) String() string {
+	s := fmt.Sprintf("verify document count of %v matches %v", r.index, r.from)
+	if r.maxDocs != -1 {
+		s = fmt.Sprintf("%v (%v max docs)", s, r.maxDocs)
+	}
+	if r.tolerance > 0 {
+		s = fmt.Sprintf("%v (tolerance %v%%)", s, r.tolerance)
+	}
+	return s
+}
+
+func (r *verifyDocCount) Resource() Resource {
+	return r.resource
+}
+
+type verifyDocCountFields struct {
+	From      string  `json:"from"`
+	Index     string  `json:"index"`
+	MaxDocs   int     `json:"maxDocs"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+func (r *verifyDocCount) MarshalJSON() ([]byte, error) {
+	return marshalAction("verifyDocCount", r.resource, verifyDocCountFields{r.from, r.index, r.maxDocs,
+		r.tolerance})
+}
+
+func (r *verifyDocCount) UnmarshalJSON(data []byte) error {
+	var f verifyDocCountFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	*r = verifyDocCount{
+		from:      f.From,
+		index:     f.Index,
+		maxDocs:   f.MaxDocs,
+		tolerance: f.Tolerance,
+		resource:  res,
+	}
+
+	return nil
+}
+
+type runCheck struct {
+	name     string
+	index    string
+	check    check
+	resource Resource
+}
+
+func (r *runCheck) Execute(es *es.Client, _ *resource.Changelog, _ *Collector) error {
+	if err := es.Refresh(r.index); err != nil {
+		return err
+	}
+
+	response, err := es.SearchResponse(r.index, r.check.request)
+
+	if err != nil {
+		return fmt.Errorf("couldn't run check %v: %w", r.name, err)
+	}
+
+	idsResponse := ""
+
+	if len(r.check.ids) > 0 {
+		request, err := r.check.idsRequest()
+
+		if err != nil {
+			return fmt.Errorf("couldn't run check %v: %w", r.name, err)
+		}
+
+		if idsResponse, err = es.SearchResponse(r.index, request); err != nil {
+			return fmt.Errorf("couldn't run check %v: %w", r.name, err)
+		}
+	}
+
+	if err = r.check.assert(response, idsResponse); err != nil {
+		return fmt.Errorf("check %v failed against %v: %w", r.name, r.index, err)
+	}
+
+	return nil
+}
+
+func (r *runCheck) String() string {
+	return fmt.Sprintf("run check %v against %v expecting %v", r.name, r.index, r.check)
+}
+
+func (r *runCheck) Resource() Resource {
+	return r.resource
+}
+
+type runCheckFields struct {
+	Name        string          `json:"name"`
+	Index       string          `json:"index"`
+	Request     json.RawMessage `json:"request"`
+	MinHits     int64           `json:"minHits"`
+	Ids         []string        `json:"ids"`
+	Aggregation string          `json:"aggregation"`
+}
+
+func (r *runCheck) MarshalJSON() ([]byte, error) {
+	return marshalAction("runCheck", r.resource, runCheckFields{r.name, r.index, json.RawMessage(r.check.request),
+		r.check.minHits, r.check.ids, r.check.aggregation})
+}
+
+func (r *runCheck) UnmarshalJSON(data []byte) error {
+	var f runCheckFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	// the request is indented along with the rest of a saved plan
+	var request bytes.Buffer
+
+	if err = json.Compact(&request, f.Request); err != nil {
+		return err
+	}
+
+	*r = runCheck{
+		name:  f.Name,
+		index: f.Index,
+		check: check{
+			request:     request.String(),
+			minHits:     f.MinHits,
+			ids:         f.Ids,
+			aggregation: f.Aggregation,
+		},
+		resource: res,
+	}
+
+	return nil
+}
+
+// healthPollInterval is the longest each request waiting for an index's health waits, between reports of progress
+var healthPollInterval = 10 * time.Second
+
+type waitForHealth struct {
+	index    string
+	status   string
+	timeout  time.Duration
+	resource Resource
+}
+
+func (r *waitForHealth) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	ctx := es.Context()
+	start := time.Now()
+
+	for {
+		wait := r.timeout - time.Since(start)
+
+		if wait > healthPollInterval {
+			wait = healthPollInterval
+		}
+
+		health, err := es.GetIndexHealth(r.index, r.status, wait)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped waiting for health of %v: %w", r.index, ctx.Err())
+			}
+			return err
+		}
+
+		if !health.TimedOut && health.IsAtLeast(r.status) {
+			return nil
+		}
+
+		waited := time.Since(start).Round(time.Second)
+
+		if waited >= r.timeout {
+			return fmt.Errorf("index %v still %v after %v, want %v", r.index, health.Status, waited, r.status)
+		}
+
+		// other index sets may be showing reindex progress at the same time
+		collector.progressDisplay().log(fmt.Sprintf("Waiting for index %v to be %v - %v with %v unassigned, %v initializing and %v "+
+			"relocating shard(s) - waited %v", r.index, r.status, health.Status, health.UnassignedShards,
+			health.InitializingShards, health.RelocatingShards, waited))
+	}
+}
+
+func (r *waitForHealth) String() string {
+	return fmt.Sprintf("wait up to %v for index %v to be %v", r.timeout, r.index, r.status)
+}
+
+func (r *waitForHealth) Resource() Resource {
+	return r.resource
+}
+
+type waitForHealthFields struct {
+	Index   string `json:"index"`
+	Status  string `json:"status"`
+	Timeout string `json:"timeout"`
+}
+
+func (r *waitForHealth) MarshalJSON() ([]byte, error) {
+	return marshalAction("waitForHealth", r.resource, waitForHealthFields{r.index, r.status, r.timeout.String()})
+}
+
+func (r *waitForHealth) UnmarshalJSON(data []byte) error {
+	var f waitForHealthFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	timeout, err := time.ParseDuration(f.Timeout)
+
+	if err != nil {
+		return err
+	}
+
+	*r = waitForHealth{
+		index:    f.Index,
+		status:   f.Status,
+		timeout:  timeout,
+		resource: res,
+	}
+
+	return nil
+}
+
+// maxCatchUpPasses is the most passes catchUp makes to get under the threshold before giving up, rather than block
+// writes for longer than the threshold allows
+const maxCatchUpPasses = 10
+
+// catchUp copies the documents written to the old indices behind an alias while reindexing from them, by a date field
+// set when each document is written. Passes are repeated until one copies no more than the threshold, then writes to
+// the old indices are blocked and a final pass made. If no pass gets under the threshold, catchUp fails without
+// blocking writes. Each pass refreshes the old indices first, so it sees every
+// write made before it started, and copies from the margin before the previous pass started. Passes aren't
+// throttled, to block writes for as short a time as possible.
+type catchUp struct {
+	from         string
+	to           string
+	blockIndices []string
+	field        string
+	threshold    int
+	margin       time.Duration
+	pipeline     string
+	slices       string
+	batchSize    int
+	resource     Resource
+}
+
+func (r *catchUp) Execute(es *es.Client, _ *resource.Changelog, collector *Collector) error {
+	since, ok := collector.reindexStarted(r.to)
+
+	if !ok {
+		return fmt.Errorf("couldn't find when reindexing to %v started", r.to)
+	}
+
+	for pass := 1; ; pass++ {
+		started := time.Now()
+
+		copied, err := r.copySince(es, collector, since, fmt.Sprintf("%v catch-up %v", r.to, pass))
+
+		if err != nil {
+			return err
+		}
+
+		since = started
+
+		if copied <= int64(r.threshold) {
+			break
+		}
+
+		if pass == maxCatchUpPasses {
+			return fmt.Errorf("couldn't catch up %v -> %v: still copying %v document(s) after %v passes, over the "+
+				"threshold of %v", r.from, r.to, copied, pass, r.threshold)
+		}
+	}
+
+	for _, index := range r.blockIndices {
+		collector.addBlockedIndex(index)
+
+		if err := es.PutSettings(index, `{"index.blocks.write":true}`); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.copySince(es, collector, since, fmt.Sprintf("%v final catch-up", r.to))
+
+	return err
+}
+
+// copySince refreshes the old indices and reindexes the documents written since the margin before the given time,
+// returning how many were copied
+func (r *catchUp) copySince(es *es.Client, collector *Collector, since time.Time, label string) (int64, error) {
+	if err := es.Refresh(r.from); err != nil {
+		return 0, fmt.Errorf("couldn't refresh %v: %w", r.from, err)
+	}
+
+	taskId, err := es.Reindex(r.from, r.to, r.options(since.Add(-r.margin)))
+
+	if err != nil {
+		if es.Context().Err() != nil {
+			collector.addPendingReindex(r.to)
+		}
+		return 0, err
+	}
+
+	collector.setReindexTask(r.to, taskId)
+
+	if collector.OnReindexStarted != nil {
+		if err = collector.OnReindexStarted(taskId); err != nil {
+			return 0, err
+		}
+	}
+
+	status, err := waitForReindex(es, collector, taskId, label)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// documents the reindex already copied are updated rather than created, so aren't counted again
+	collector.setReindexedDocs(r.to, status.Created, true)
+
+	return status.Done, nil
+}
+
+func (r *catchUp) options(since time.Time) es.ReindexOptions {
+	return es.ReindexOptions{
+		MaxDocs:   -1,
+		Pipeline:  r.pipeline,
+		Slices:    r.slices,
+		BatchSize: r.batchSize,
+		Query: map[string]interface{}{
+			"range": map[string]interface{}{
+				r.field: map[string]interface{}{
+					"gte":    since.UnixNano() / int64(time.Millisecond),
+					"format": "epoch_millis",
+				},
+			},
+		},
+	}
+}
+
+func (r *catchUp) String() string {
+	return fmt.Sprintf("catch up %v -> %v by %v until under %v document(s), then block writes to %v and catch up "+
+		"again", r.from, r.to, r.field, r.threshold, strings.Join(r.blockIndices, ", "))
+}
+
+func (r *catchUp) Resource() Resource {
+	return r.resource
+}
+
+type catchUpFields struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	BlockIndices []string `json:"blockIndices"`
+	Field        string   `json:"field"`
+	Threshold    int      `json:"threshold"`
+	Margin       string   `json:"margin"`
+	Pipeline     string   `json:"pipeline"`
+	Slices       string   `json:"slices,omitempty"`
+	BatchSize    int      `json:"batchSize,omitempty"`
+}
+
+func (r *catchUp) MarshalJSON() ([]byte, error) {
+	return marshalAction("catchUp", r.resource, catchUpFields{r.from, r.to, r.blockIndices, r.field, r.threshold,
+		r.margin.String(), r.pipeline, r.slices, r.batchSize})
+}
+
+func (r *catchUp) UnmarshalJSON(data []byte) error {
+	var f catchUpFields
+
+	res, err := unmarshalAction(data, &f)
+
+	if err != nil {
+		return err
+	}
+
+	margin, err := time.ParseDuration(f.Margin)
+
+	if err != nil {
+		return err
+	}
+
+	*r = catchUp{
+		from:         f.From,
+		to:           f.To,
+		blockIndices: f.BlockIndices,
+		field:        f.Field,
+		threshold:    f.Threshold,
+		margin:       margin,
+		pipeline:     f.Pipeline,
+		slices:       f.Slices,
+		batchSize:    f.BatchSize,
+		resource:     res,
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"strings"
+)
+
+// check is a search run against a new index before the alias is pointed at it, and what's expected of its results
+type check struct {
+	// request is the search request body
+	request string
+	// minHits is the fewest hits the search must find, or 0 for any number
+	minHits int64
+	// ids are the IDs of documents the search must find
+	ids []string
+	// aggregation is the name of an aggregation the search results must include
+	aggregation string
+}
+
+type checkFields struct {
+	Request     json.RawMessage `json:"request"`
+	MinHits     int64           `json:"minHits"`
+	Ids         []string        `json:"ids"`
+	Aggregation string          `json:"aggregation"`
+}
+
+func parseCheck(content string) (check, error) {
+	var f checkFields
+
+	if err := json.Unmarshal([]byte(content), &f); err != nil {
+		return check{}, err
+	}
+
+	if !gjson.ParseBytes(f.Request).IsObject() {
+		return check{}, errors.New("request should be a search request body")
+	}
+
+	if f.MinHits < 0 {
+		return check{}, errors.New("minHits can't be negative")
+	}
+
+	if f.MinHits == 0 && len(f.Ids) == 0 && f.Aggregation == "" {
+		return check{}, errors.New("expected at least one of minHits, ids or aggregation")
+	}
+
+	var request bytes.Buffer
+
+	if err := json.Compact(&request, f.Request); err != nil {
+		return check{}, err
+	}
+
+	return check{
+		request:     request.String(),
+		minHits:     f.MinHits,
+		ids:         f.Ids,
+		aggregation: f.Aggregation,
+	}, nil
+}
+
+// idsRequest returns the check's request with its hits filtered to the documents with the check's IDs, so they're
+// found however many other documents the request matches. The filter is a post filter, leaving the query as it is.
+func (c check) idsRequest() (string, error) {
+	var request map[string]interface{}
+
+	if err := json.Unmarshal([]byte(c.request), &request); err != nil {
+		return "", err
+	}
+
+	filter := map[string]interface{}{"ids": map[string]interface{}{"values": c.ids}}
+
+	if existing, ok := request["post_filter"]; ok {
+		filter = map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{existing, filter}}}
+	}
+
+	request["post_filter"] = filter
+	request["size"] = len(c.ids)
+	request["_source"] = false
+
+	b, err := json.Marshal(request)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// assert returns an error describing how a search response falls short of what the check expects, given the
+// response to idsRequest if the check expects IDs
+func (c check) assert(response string, idsResponse string) error {
+	failures := make([]string, 0)
+
+	if c.minHits > 0 {
+		total := gjson.Get(response, "hits.total")
+
+		if total.IsObject() {
+			total = total.Get("value")
+		}
+
+		if got := total.Int(); got < c.minHits {
+			failures = append(failures, fmt.Sprintf("got %v hit(s), want at least %v", got, c.minHits))
+		}
+	}
+
+	if len(c.ids) > 0 {
+		found := make(map[string]bool)
+
+		for _, hit := range gjson.Get(idsResponse, "hits.hits").Array() {
+			found[hit.Get("_id").String()] = true
+		}
+
+		missing := make([]string, 0)
+
+		for _, id := range c.ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+
+		if len(missing) > 0 {
+			failures = append(failures, fmt.Sprintf("didn't find document(s) %v", strings.Join(missing, ", ")))
+		}
+	}
+
+	if c.aggregation != "" {
+		if _, ok := gjson.Get(response, "aggregations").Map()[c.aggregation]; !ok {
+			failures = append(failures, fmt.Sprintf("didn't get aggregation %v", c.aggregation))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+func (c check) String() string {
+	expectations := make([]string, 0)
+
+	if c.minHits > 0 {
+		expectations = append(expectations, fmt.Sprintf("at least %v hit(s)", c.minHits))
+	}
+
+	if len(c.ids) > 0 {
+		expectations = append(expectations, fmt.Sprintf("document(s) %v", strings.Join(c.ids, ", ")))
+	}
+
+	if c.aggregation != "" {
+		expectations = append(expectations, fmt.Sprintf("aggregation %v", c.aggregation))
+	}
+
+	return strings.Join(expectations, ", ")
+}
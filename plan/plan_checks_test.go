@@ -0,0 +1,133 @@
+package plan
+
+import (
+	"errors"
+	"github.com/hdpe.me/esup/testutil"
+	"reflect"
+	"testing"
+)
+
+func Test_parseCheck(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		content     string
+		expected    check
+		expectedErr error
+	}{
+		{
+			desc: "all assertions",
+			content: `{
+  "request": { "query": { "match_all": {} } },
+  "minHits": 2,
+  "ids": ["a", "b"],
+  "aggregation": "x"
+}`,
+			expected: check{
+				request:     `{"query":{"match_all":{}}}`,
+				minHits:     2,
+				ids:         []string{"a", "b"},
+				aggregation: "x",
+			},
+		},
+		{
+			desc:        "no request",
+			content:     `{"minHits": 1}`,
+			expectedErr: errors.New("request should be a search request body"),
+		},
+		{
+			desc:        "no assertion",
+			content:     `{"request": {}}`,
+			expectedErr: errors.New("expected at least one of minHits, ids or aggregation"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseCheck(tc.content)
+
+			if !testutil.ErrorsEqual(err, tc.expectedErr) {
+				t.Errorf("got error %v; want %v", err, tc.expectedErr)
+			}
+
+			if err == nil && !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got %+v; want %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func Test_check_assert(t *testing.T) {
+	response := `{
+  "hits": {
+    "total": { "value": 2, "relation": "eq" },
+    "hits": [{ "_id": "a" }, { "_id": "b" }]
+  },
+  "aggregations": { "x": { "buckets": [] } }
+}`
+
+	testCases := []struct {
+		desc        string
+		check       check
+		expectedErr error
+	}{
+		{
+			desc:  "passes",
+			check: check{minHits: 2, ids: []string{"b"}, aggregation: "x"},
+		},
+		{
+			desc:        "too few hits",
+			check:       check{minHits: 3},
+			expectedErr: errors.New("got 2 hit(s), want at least 3"),
+		},
+		{
+			desc:        "missing documents and aggregation",
+			check:       check{ids: []string{"a", "c", "d"}, aggregation: "y"},
+			expectedErr: errors.New("didn't find document(s) c, d; didn't get aggregation y"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := tc.check.assert(response, response); !testutil.ErrorsEqual(err, tc.expectedErr) {
+				t.Errorf("got error %v; want %v", err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func Test_check_idsRequest(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		request  string
+		expected string
+	}{
+		{
+			desc:     "adds post filter",
+			request:  `{"query":{"match_all":{}},"size":0}`,
+			expected: `{"_source":false,"post_filter":{"ids":{"values":["a","b"]}},"query":{"match_all":{}},"size":2}`,
+		},
+		{
+			desc:    "combines with existing post filter",
+			request: `{"post_filter":{"term":{"k":"v"}}}`,
+			expected: `{"_source":false,"post_filter":{"bool":{"filter":[{"term":{"k":"v"}},` +
+				`{"ids":{"values":["a","b"]}}]}},"size":2}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := check{request: tc.request, ids: []string{"a", "b"}}.idsRequest()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tc.expected {
+				t.Errorf("got %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hdpe.me/esup/diff"
+	"github.com/tidwall/gjson"
+	"reflect"
+	"sort"
+)
+
+// Drift is a difference between a resource as it is in Elasticsearch and as esup last left it
+type Drift struct {
+	Resource    Resource
+	Description string
+	// Changes are the changes made in Elasticsearch, if Description refers to the resource's content
+	Changes []diff.Change
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%v %v: %v", d.Resource.Type, d.Resource.Identifier, d.Description)
+}
+
+// Drift compares the live pipelines, index sets and documents in Elasticsearch with their last changelog entries
+func (r *Planner) Drift() ([]Drift, error) {
+	drift := make([]Drift, 0)
+
+	if err := r.appendPipelineDrift(&drift); err != nil {
+		return nil, fmt.Errorf("couldn't get pipeline drift: %w", err)
+	}
+
+	if err := r.appendIndexSetDrift(&drift); err != nil {
+		return nil, fmt.Errorf("couldn't get index set drift: %w", err)
+	}
+
+	if err := r.appendDocumentDrift(&drift); err != nil {
+		return nil, fmt.Errorf("couldn't get document drift: %w", err)
+	}
+
+	return drift, nil
+}
+
+func (r *Planner) appendPipelineDrift(drift *[]Drift) error {
+	for _, p := range r.schema.Pipelines {
+		res := Resource{Type: "pipeline", Identifier: p.Name}
+
+		entry, err := r.changelog.GetCurrentChangelogEntry("pipeline", p.Name, r.envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entry for %v: %w", p.Name, err)
+		}
+
+		if !entry.IsPresent || entry.Deleted {
+			continue
+		}
+
+		liveDef, err := r.es.GetPipelineDef(entry.FinalName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get pipeline %v: %w", entry.FinalName, err)
+		}
+
+		if liveDef == "" {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf("pipeline %v is missing",
+				entry.FinalName)})
+			continue
+		}
+
+		changes, err := diff.Changes(liveDef, entry.Content)
+
+		if err != nil {
+			return fmt.Errorf("couldn't diff pipeline %v with changelog: %w", entry.FinalName, err)
+		}
+
+		if len(changes) > 0 {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf(
+				"pipeline %v differs from changelog", entry.FinalName), Changes: changes})
+		}
+	}
+
+	return nil
+}
+
+func (r *Planner) appendIndexSetDrift(drift *[]Drift) error {
+	for _, is := range r.schema.IndexSets {
+		res := Resource{Type: "index_set", Identifier: is.ResourceIdentifier()}
+
+		entry, err := r.changelog.GetCurrentChangelogEntry("index_set", is.ResourceIdentifier(), r.envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entry for %v: %w", is.ResourceIdentifier(), err)
+		}
+
+		if !entry.IsPresent {
+			continue
+		}
+
+		aliasName := newAliasName(is.IndexSet, r.envName)
+		indices, err := r.es.GetIndicesForAlias(aliasName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get alias %v: %w", aliasName, err)
+		}
+
+		if indices == nil {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf("alias %v is missing", aliasName)})
+			continue
+		}
+
+		sort.Strings(indices)
+
+		if entry.FinalName != "" && !reflect.DeepEqual(indices, []string{entry.FinalName}) {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf(
+				"alias %v points to %v, not %v", aliasName, indices, entry.FinalName)})
+		}
+
+		// static index sets don't define their index
+		if entry.Content == "" {
+			continue
+		}
+
+		liveDefs, err := r.es.GetIndexDef(aliasName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get indices for %v: %w", aliasName, err)
+		}
+
+		var liveDefsByIndex map[string]json.RawMessage
+
+		if err = json.Unmarshal([]byte(liveDefs), &liveDefsByIndex); err != nil {
+			return fmt.Errorf("couldn't parse indices for %v: %w", aliasName, err)
+		}
+
+		for _, index := range indices {
+			changes, err := indexDefDrift(entry.Content, gjson.ParseBytes(liveDefsByIndex[index]))
+
+			if err != nil {
+				return fmt.Errorf("couldn't diff index %v with changelog: %w", index, err)
+			}
+
+			if len(changes) > 0 {
+				*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf(
+					"index %v differs from changelog", index), Changes: changes})
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Planner) appendDocumentDrift(drift *[]Drift) error {
+	for _, doc := range r.schema.Documents {
+		res := Resource{Type: "document", Identifier: doc.ResourceIdentifier()}
+
+		entry, err := r.changelog.GetCurrentChangelogEntry("document", doc.ResourceIdentifier(), r.envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entry for %v: %w", doc.ResourceIdentifier(), err)
+		}
+
+		if !entry.IsPresent || doc.Meta.Ignored {
+			continue
+		}
+
+		index := newAliasName(doc.IndexSet, r.envName)
+		live, err := r.es.GetDocument(index, doc.Name)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get document %v/%v: %w", index, doc.Name, err)
+		}
+
+		if !live.IsPresent() {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf("document %v/%v is missing",
+				index, doc.Name)})
+			continue
+		}
+
+		changes, err := diff.Changes(live.Source(), entry.Content)
+
+		if err != nil {
+			return fmt.Errorf("couldn't diff document %v/%v with changelog: %w", index, doc.Name, err)
+		}
+
+		if len(changes) > 0 {
+			*drift = append(*drift, Drift{Resource: res, Description: fmt.Sprintf(
+				"document %v/%v differs from changelog", index, doc.Name), Changes: changes})
+		}
+	}
+
+	return nil
+}
+
+// indexDefDrift compares a live index's mapping, and those of its settings which the definition specifies, with
+// the definition
+func indexDefDrift(def string, live gjson.Result) ([]diff.Change, error) {
+	parsed, err := parseIndexDef(def)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var liveMapping interface{}
+
+	if err = json.Unmarshal([]byte(live.Get("mappings").Raw), &liveMapping); err != nil {
+		return nil, fmt.Errorf("couldn't parse live mapping: %w", err)
+	}
+
+	var liveSettings interface{}
+
+	if err = json.Unmarshal([]byte(live.Get("settings").Raw), &liveSettings); err != nil {
+		return nil, fmt.Errorf("couldn't parse live settings: %w", err)
+	}
+
+	// Elasticsearch returns settings as strings
+	defSettings := make(map[string]interface{})
+	liveFlattened := flattenSettings(liveSettings)
+	comparedSettings := make(map[string]interface{})
+
+	for k, v := range flattenSettings(parsed["settings"]) {
+		defSettings[k] = settingAsString(v)
+
+		if liveValue, ok := liveFlattened[k]; ok {
+			comparedSettings[k] = liveValue
+		}
+	}
+
+	defDoc, err := json.Marshal(map[string]interface{}{
+		"mappings": asObject(parsed["mappings"]),
+		"settings": defSettings,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	liveDoc, err := json.Marshal(map[string]interface{}{
+		"mappings": asObject(liveMapping),
+		"settings": comparedSettings,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Changes(string(liveDoc), string(defDoc))
+}
+
+func settingAsString(value interface{}) interface{} {
+	if values, ok := value.([]interface{}); ok {
+		result := make([]interface{}, 0)
+		for _, v := range values {
+			result = append(result, settingAsString(v))
+		}
+		return result
+	}
+	if value == nil {
+		return nil
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,54 @@
+package plan
+
+import (
+	"github.com/tidwall/gjson"
+	"reflect"
+	"testing"
+)
+
+func Test_indexDefDrift(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		def      string
+		live     string
+		expected []string
+	}{
+		{
+			desc: "no drift, ignoring unspecified settings",
+			def:  `{"settings":{"number_of_replicas":0},"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			live: `{"aliases":{},"mappings":{"properties":{"a":{"type":"text"}}},` +
+				`"settings":{"index":{"number_of_replicas":"0","number_of_shards":"1","uuid":"x"}}}`,
+			expected: []string{},
+		},
+		{
+			desc: "field added and setting changed by hand",
+			def:  `{"settings":{"index.refresh_interval":"1s"},"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			live: `{"aliases":{},"mappings":{"properties":{"a":{"type":"text"},"b":{"type":"long"}}},` +
+				`"settings":{"index":{"refresh_interval":"30s"}}}`,
+			expected: []string{
+				`+ mappings.properties.b: {"type":"long"}`,
+				`~ settings.refresh_interval: "1s" -> "30s"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			changes, err := indexDefDrift(tc.def, gjson.Parse(tc.live))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0)
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/resource"
+	"sort"
+	"sync"
+	"time"
+)
+
+func NewExecutor(es *es.Client, changelog *resource.Changelog, journal *resource.Journal,
+	envName string) *Executor {
+
+	return &Executor{
+		es:          es,
+		changelog:   changelog,
+		journal:     journal,
+		envName:     envName,
+		undo:        true,
+		ctx:         context.Background(),
+		concurrency: 1,
+	}
+}
+
+// Executor executes plans, recording their progress in the journal so an interrupted run can be resumed, and
+// undoing a failed run
+type Executor struct {
+	es        *es.Client
+	changelog *resource.Changelog
+	journal   *resource.Journal
+	envName   string
+	undo      bool
+	ctx       context.Context
+	// concurrency is the most index sets whose actions are executed at once
+	concurrency int
+}
+
+// SetUndo sets whether the changes made by a failed run are undone
+func (r *Executor) SetUndo(undo bool) {
+	r.undo = undo
+}
+
+// SetContext sets a context which interrupts a run when cancelled. The interrupted run is then undone, or left
+// to be resumed, as though it had failed.
+func (r *Executor) SetContext(ctx context.Context) {
+	r.ctx = ctx
+}
+
+// SetConcurrency sets the most index sets whose actions are executed at once
+func (r *Executor) SetConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	r.concurrency = concurrency
+}
+
+// Run is a run of a plan recorded in the journal
+type Run struct {
+	Plan      []PlanAction
+	Completed int
+	Started   string
+	// done are the positions in the plan of the actions completed
+	done      map[int]bool
+	collector *Collector
+}
+
+// Remaining returns the actions in the run which haven't completed
+func (r Run) Remaining() []PlanAction {
+	remaining := make([]PlanAction, 0)
+
+	for i, item := range r.Plan {
+		if !r.done[i] {
+			remaining = append(remaining, item)
+		}
+	}
+
+	return remaining
+}
+
+// ExecutionError is returned when a plan action fails, along with the steps taken to undo the run
+type ExecutionError struct {
+	Action PlanAction
+	Err    error
+	// Interrupted is whether the action failed because the run was interrupted
+	Interrupted bool
+	// Undo is nil if the run wasn't undone
+	Undo []UndoStep
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("couldn't execute %v: %v", e.Action, e.Err)
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// Execute executes a plan from the start
+func (r *Executor) Execute(plan []PlanAction) error {
+	return r.execute(Run{Plan: plan, done: map[int]bool{}, collector: NewCollector()})
+}
+
+// UnfinishedRun returns the run in the journal which was interrupted, or which failed without being undone, if
+// there is one
+func (r *Executor) UnfinishedRun() (Run, bool, error) {
+	entry, err := r.journal.Get(r.envName)
+
+	if err != nil {
+		return Run{}, false, fmt.Errorf("couldn't get journal: %w", err)
+	}
+
+	if !entry.IsPresent || entry.Status != es.JournalStatusRunning {
+		return Run{}, false, nil
+	}
+
+	plan, err := UnmarshalPlan([]byte(entry.Plan))
+
+	if err != nil {
+		return Run{}, false, fmt.Errorf("couldn't read journal: %w", err)
+	}
+
+	collector := NewCollector()
+
+	if err = json.Unmarshal([]byte(entry.Collector), collector); err != nil {
+		return Run{}, false, fmt.Errorf("couldn't read journal: %w", err)
+	}
+
+	done := make(map[int]bool)
+
+	for _, i := range entry.Done {
+		done[i] = true
+	}
+
+	return Run{
+		Plan:      plan,
+		Completed: len(done),
+		Started:   entry.Started,
+		done:      done,
+		collector: collector,
+	}, true, nil
+}
+
+// Resume executes the actions in a run which haven't completed
+func (r *Executor) Resume(run Run) error {
+	return r.execute(run)
+}
+
+func (r *Executor) execute(run Run) error {
+	planJson, err := MarshalPlan(run.Plan)
+
+	if err != nil {
+		return fmt.Errorf("couldn't marshal plan for journal: %w", err)
+	}
+
+	coll := run.collector
+
+	if run.Started == "" {
+		run.Started = es.FormatTimestamp(time.Now())
+	}
+
+	// actions executing concurrently record their progress one at a time, so the last recorded is the latest
+	var mu sync.Mutex
+
+	record := func(status string, done ...int) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, i := range done {
+			run.done[i] = true
+		}
+
+		collJson, err := coll.marshal()
+
+		if err != nil {
+			return err
+		}
+
+		positions := make([]int, 0, len(run.done))
+
+		for i := range run.done {
+			positions = append(positions, i)
+		}
+
+		sort.Ints(positions)
+
+		return r.journal.Put(r.envName, es.JournalEntry{
+			Status:    status,
+			Plan:      string(planJson),
+			Completed: len(positions),
+			Done:      positions,
+			Collector: string(collJson),
+			Started:   run.Started,
+		})
+	}
+
+	if err = record(es.JournalStatusRunning); err != nil {
+		return fmt.Errorf("couldn't record run in journal: %w", err)
+	}
+
+	coll.OnReindexStarted = func(string) error {
+		return record(es.JournalStatusRunning)
+	}
+
+	// a failed action cancels the actions executing alongside it
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	r.es.SetContext(ctx)
+	defer r.es.SetContext(context.Background())
+
+	var execErr *ExecutionError
+
+	for _, stage := range stages(run.Plan, run.done) {
+		if execErr = r.executeStage(ctx, cancel, run.Plan, stage, coll, record); execErr != nil {
+			break
+		}
+	}
+
+	if execErr != nil {
+		execErr.Interrupted = r.ctx.Err() != nil
+
+		// cleaning up after an interrupted run still needs to reach Elasticsearch
+		r.es.SetContext(context.Background())
+
+		if r.undo {
+			execErr.Undo = Undo(r.es, coll)
+
+			if err := record(es.JournalStatusUndone); err != nil {
+				println(fmt.Sprintf("couldn't record undone run in journal: %v", err))
+			}
+		} else if err := record(es.JournalStatusRunning); err != nil {
+			// the collector may hold pending requests to look for when the run is resumed
+			println(fmt.Sprintf("couldn't record failed run in journal: %v", err))
+		}
+
+		return execErr
+	}
+
+	if err = record(es.JournalStatusComplete); err != nil {
+		return fmt.Errorf("couldn't record completed run in journal: %v", err)
+	}
+
+	return nil
+}
+
+// executeStage executes each group of actions in a stage concurrently, up to the executor's concurrency, and the
+// actions in each group in order, returning an error for the first action to fail
+func (r *Executor) executeStage(ctx context.Context, cancel func(), plan []PlanAction, stage [][]int,
+	coll *Collector, record func(status string, done ...int) error) *ExecutionError {
+
+	var mu sync.Mutex
+	var execErr *ExecutionError
+
+	fail := func(item PlanAction, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// the first failure cancels the other groups, whose failures follow from it
+		if execErr == nil {
+			execErr = &ExecutionError{Action: item, Err: err}
+			cancel()
+		}
+	}
+
+	slots := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+
+	for _, group := range stage {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(group []int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			for _, i := range group {
+				if ctx.Err() != nil {
+					fail(plan[i], ctx.Err())
+					return
+				}
+
+				err := plan[i].Execute(r.es, r.changelog, coll)
+
+				if err == nil {
+					err = record(es.JournalStatusRunning, i)
+				}
+
+				if err != nil {
+					fail(plan[i], err)
+					return
+				}
+			}
+		}(group)
+	}
+
+	wg.Wait()
+
+	return execErr
+}
+
+// stages divides the actions in a plan not yet done into stages to execute one after another. Consecutive actions
+// for index sets make up a stage, grouped by index set: the actions for each index set must execute in order, but
+// the groups are independent of each other. Any other action makes up a stage of its own.
+func stages(plan []PlanAction, done map[int]bool) [][][]int {
+	result := make([][][]int, 0)
+
+	var stage [][]int
+	groups := make(map[string]int)
+
+	endStage := func() {
+		if len(stage) > 0 {
+			result = append(result, stage)
+		}
+		stage = nil
+		groups = make(map[string]int)
+	}
+
+	for i, item := range plan {
+		if done[i] {
+			continue
+		}
+
+		res := item.Resource()
+
+		if res.Type != "index_set" {
+			endStage()
+			result = append(result, [][]int{{i}})
+			continue
+		}
+
+		if g, ok := groups[res.Identifier]; ok {
+			stage[g] = append(stage[g], i)
+		} else {
+			groups[res.Identifier] = len(stage)
+			stage = append(stage, []int{i})
+		}
+	}
+
+	endStage()
+
+	return result
+}
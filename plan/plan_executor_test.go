@@ -0,0 +1,194 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"github.com/hdpe.me/esup/schema"
+	"reflect"
+	"testing"
+)
+
+func Test_stages(t *testing.T) {
+	pipeline := Resource{Type: "pipeline", Identifier: "p"}
+	x := Resource{Type: "index_set", Identifier: "x"}
+	y := Resource{Type: "index_set", Identifier: "y"}
+	doc := Resource{Type: "document", Identifier: "d"}
+
+	plan := []PlanAction{
+		&putPipeline{id: "env-p", resource: pipeline},
+		&createIndex{name: "env-x_1", resource: x},
+		&createIndex{name: "env-y_1", resource: y},
+		&reindex{from: "env-x", to: "env-x_1", resource: x},
+		&reindex{from: "env-y", to: "env-y_1", resource: y},
+		&writeChangelogEntry{resourceIdentifier: "x", resource: x},
+		&writeChangelogEntry{resourceIdentifier: "y", resource: y},
+		&indexDocument{id: "d", resource: doc},
+		&deleteIndex{name: "env-x_0", resource: x},
+	}
+
+	testCases := []struct {
+		desc string
+		done map[int]bool
+		want [][][]int
+	}{
+		{
+			desc: "groups consecutive index set actions by index set",
+			done: map[int]bool{},
+			want: [][][]int{
+				{{0}},
+				{{1, 3, 5}, {2, 4, 6}},
+				{{7}},
+				{{8}},
+			},
+		},
+		{
+			desc: "skips actions done",
+			done: map[int]bool{0: true, 1: true, 2: true, 4: true},
+			want: [][][]int{
+				{{3, 5}, {6}},
+				{{7}},
+				{{8}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := stages(plan, tc.done); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecutor_Resume(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	c, err := NewElasticsearchContainer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := c.Terminate(); err != nil {
+			println(err)
+		}
+	}()
+
+	ctx, err := GetContext(c, schema.Schema{EnvName: "env"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll := NewCollector()
+	coll.Indices = append(coll.Indices, "env-x_1", "env-y_1", ctx.Conf.Changelog.JournalIndex)
+	defer CleanUp(ctx, coll)
+
+	plan := []PlanAction{
+		&createIndex{name: "env-x_1", definition: "{}"},
+		&createAlias{name: "env-y", index: "env-y_1"},
+	}
+
+	executor := NewExecutor(ctx.Es, ctx.Changelog, ctx.Journal, "env")
+	executor.SetUndo(false)
+
+	var execErr *ExecutionError
+
+	if err = executor.Execute(plan); !errors.As(err, &execErr) {
+		t.Fatalf("got error %v, want execution error", err)
+	}
+
+	run, ok, err := executor.UnfinishedRun()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatalf("wanted unfinished run")
+	}
+
+	if got, want := run.Completed, 1; got != want {
+		t.Errorf("got %v completed action(s), want %v", got, want)
+	}
+
+	if err = ctx.Es.CreateIndex("env-y_1", "{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = executor.Resume(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if indices, _ := ctx.Es.GetIndicesForAlias("env-y"); len(indices) != 1 {
+		t.Errorf("wanted alias env-y created on resume")
+	}
+
+	if _, ok, _ = executor.UnfinishedRun(); ok {
+		t.Errorf("wanted no unfinished run after resume")
+	}
+}
+
+func TestExecutor_Interrupted(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	c, err := NewElasticsearchContainer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := c.Terminate(); err != nil {
+			println(err)
+		}
+	}()
+
+	ctx, err := GetContext(c, schema.Schema{EnvName: "env"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll := NewCollector()
+	coll.Indices = append(coll.Indices, "env-x_1", ctx.Conf.Changelog.JournalIndex)
+	defer CleanUp(ctx, coll)
+
+	plan := []PlanAction{
+		&createIndex{name: "env-x_1", definition: "{}"},
+	}
+
+	interrupt, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	executor := NewExecutor(ctx.Es, ctx.Changelog, ctx.Journal, "env")
+	executor.SetContext(interrupt)
+
+	var execErr *ExecutionError
+
+	if err = executor.Execute(plan); !errors.As(err, &execErr) {
+		t.Fatalf("got error %v, want execution error", err)
+	}
+
+	if !execErr.Interrupted {
+		t.Errorf("wanted interrupted run")
+	}
+
+	if execErr.Undo == nil {
+		t.Errorf("wanted interrupted run undone")
+	}
+
+	if ctx.Es.Context().Err() != nil {
+		t.Errorf("wanted client usable after interrupted run")
+	}
+
+	if _, ok, _ := executor.UnfinishedRun(); ok {
+		t.Errorf("wanted no unfinished run after undo")
+	}
+}
@@ -0,0 +1,86 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/resource"
+	"regexp"
+	"sort"
+)
+
+// Orphans finds the indices named like an index set index of the given environment, or of any environment in the
+// changelog if envName is "", which no alias references and no changelog entry names, e.g. those left behind by
+// failed migrations or index sets since removed from the schema. Index sets are those recorded in the changelog for
+// the environment, along with indexSets, which are the given environment's index sets in the schema.
+func Orphans(es *es.Client, changelog *resource.Changelog, envName string,
+	indexSets []string) (orphans []es.IndexInfo, err error) {
+
+	entries, err := changelog.GetAllChangelogEntries()
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get changelog entries: %w", err)
+	}
+
+	named := make(map[string]bool)
+	envIndexSets := make(map[string]map[string]bool)
+
+	addIndexSet := func(env string, indexSet string) {
+		if envIndexSets[env] == nil {
+			envIndexSets[env] = make(map[string]bool)
+		}
+		envIndexSets[env][indexSet] = true
+	}
+
+	for _, entry := range entries {
+		named[entry.FinalName] = true
+
+		if entry.ResourceType == "index_set" && (envName == "" || entry.EnvName == envName) {
+			addIndexSet(entry.EnvName, entry.ResourceIdentifier)
+		}
+	}
+
+	for _, indexSet := range indexSets {
+		addIndexSet(envName, indexSet)
+	}
+
+	for env, sets := range envIndexSets {
+		for indexSet := range sets {
+			pattern := newIndexName(indexSet, env, "*")
+
+			indices, err := es.GetIndices(pattern)
+
+			if err != nil {
+				return nil, err
+			}
+
+			aliases, err := es.GetAliases(pattern)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, index := range indices {
+				if !isVersionedIndex(env, indexSet, index.Name) {
+					continue
+				}
+
+				if len(aliases[index.Name]) == 0 && !named[index.Name] {
+					orphans = append(orphans, index)
+				}
+			}
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Name < orphans[j].Name
+	})
+
+	return orphans, nil
+}
+
+// isVersionedIndex returns whether index is named like an index esup creates for the index set in the environment,
+// with the default timestamp version suffix
+func isVersionedIndex(envName string, indexSet string, index string) bool {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(newIndexName(indexSet, envName, "")) + `\d{14}$`).
+		MatchString(index)
+}
@@ -0,0 +1,26 @@
+package plan
+
+import (
+	"testing"
+)
+
+func Test_isVersionedIndex(t *testing.T) {
+	testCases := []struct {
+		index string
+		want  bool
+	}{
+		{index: "dev-x_20010203040506", want: true},
+		{index: "dev-x_y_20010203040506", want: false},
+		{index: "dev-eu-x_20010203040506", want: false},
+		{index: "dev-x_static", want: false},
+		{index: "prod-x_20010203040506", want: false},
+		{index: "dev-x", want: false},
+		{index: "devx-x_20010203040506", want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := isVersionedIndex("dev", "x", tc.index); got != tc.want {
+			t.Errorf("isVersionedIndex(%q): got %v, want %v", tc.index, got, tc.want)
+		}
+	}
+}
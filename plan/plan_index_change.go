@@ -0,0 +1,256 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// indexChange describes whether a change to an index definition can be applied to the existing index in place,
+// and if so, the requests to apply it
+type indexChange struct {
+	compatible bool
+	// mapping is the request body to put the new mapping, or "" if the mapping is unchanged
+	mapping string
+	// settings is the request body to put the changed settings, or "" if the settings are unchanged
+	settings string
+}
+
+// dynamicSettings are the index settings, without their "index." prefix, which can be updated on an open index
+var dynamicSettings = []string{
+	"number_of_replicas",
+	"auto_expand_replicas",
+	"refresh_interval",
+	"max_result_window",
+	"max_inner_result_window",
+	"max_rescore_window",
+	"max_docvalue_fields_search",
+	"max_script_fields",
+	"max_ngram_diff",
+	"max_shingle_diff",
+	"max_refresh_listeners",
+	"max_terms_count",
+	"max_regex_length",
+	"default_pipeline",
+	"final_pipeline",
+	"gc_deletes",
+	"hidden",
+	"search.idle.after",
+	"analyze.max_token_count",
+	"highlight.max_analyzed_offset",
+	"mapping.total_fields.limit",
+	"mapping.depth.limit",
+	"mapping.nested_fields.limit",
+	"mapping.nested_objects.limit",
+	"mapping.field_name_length.limit",
+	"unassigned.node_left.delayed_timeout",
+	"translog.durability",
+	"translog.flush_threshold_size",
+}
+
+// dynamicSettingPrefixes are the prefixes of groups of index settings which can be updated on an open index
+var dynamicSettingPrefixes = []string{
+	"blocks.",
+	"routing.allocation.",
+	"routing.rebalance.",
+	"search.slowlog.",
+	"indexing.slowlog.",
+}
+
+// classifyIndexChange works out whether an index created with oldDef can be changed to newDef without reindexing:
+// the new mapping may only add fields, and only dynamic settings may change
+func classifyIndexChange(oldDef string, newDef string) (indexChange, error) {
+	oldParsed, err := parseIndexDef(oldDef)
+
+	if err != nil {
+		return indexChange{}, fmt.Errorf("couldn't parse existing index definition: %w", err)
+	}
+
+	newParsed, err := parseIndexDef(newDef)
+
+	if err != nil {
+		return indexChange{}, fmt.Errorf("couldn't parse new index definition: %w", err)
+	}
+
+	for _, key := range unionKeys(oldParsed, newParsed) {
+		if key != "mappings" && key != "settings" && !reflect.DeepEqual(oldParsed[key], newParsed[key]) {
+			return indexChange{}, nil
+		}
+	}
+
+	change := indexChange{compatible: true}
+
+	oldMapping, newMapping := asObject(oldParsed["mappings"]), asObject(newParsed["mappings"])
+
+	if !reflect.DeepEqual(oldMapping, newMapping) {
+		if !isAdditiveMapping(oldMapping, newMapping) {
+			return indexChange{}, nil
+		}
+
+		b, err := json.Marshal(newMapping)
+
+		if err != nil {
+			return indexChange{}, err
+		}
+
+		change.mapping = string(b)
+	}
+
+	oldSettings := flattenSettings(oldParsed["settings"])
+	newSettings := flattenSettings(newParsed["settings"])
+	changedSettings := make(map[string]interface{})
+
+	for _, key := range unionKeys(oldSettings, newSettings) {
+		newValue, ok := newSettings[key]
+
+		if ok && reflect.DeepEqual(oldSettings[key], newValue) {
+			continue
+		}
+
+		if !isDynamicSetting(key) {
+			return indexChange{}, nil
+		}
+
+		// a nil value resets a removed setting to its default
+		changedSettings[fmt.Sprintf("index.%v", key)] = newValue
+	}
+
+	if len(changedSettings) > 0 {
+		b, err := json.Marshal(changedSettings)
+
+		if err != nil {
+			return indexChange{}, err
+		}
+
+		change.settings = string(b)
+	}
+
+	return change, nil
+}
+
+func parseIndexDef(def string) (map[string]interface{}, error) {
+	parsed := make(map[string]interface{})
+
+	if def == "" {
+		return parsed, nil
+	}
+
+	if err := json.Unmarshal([]byte(def), &parsed); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// isAdditiveMapping returns whether newMapping only adds fields to oldMapping
+func isAdditiveMapping(oldMapping map[string]interface{}, newMapping map[string]interface{}) bool {
+	return isAdditiveObject(oldMapping, newMapping, "")
+}
+
+func isAdditiveObject(oldObj map[string]interface{}, newObj map[string]interface{}, key string) bool {
+	for k, oldValue := range oldObj {
+		newValue, ok := newObj[k]
+
+		if !ok {
+			return false
+		}
+
+		oldChild, oldIsObj := oldValue.(map[string]interface{})
+		newChild, newIsObj := newValue.(map[string]interface{})
+
+		if oldIsObj && newIsObj {
+			if !isAdditiveObject(oldChild, newChild, k) {
+				return false
+			}
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			return false
+		}
+	}
+
+	for k := range newObj {
+		if _, ok := oldObj[k]; ok {
+			continue
+		}
+
+		// new fields and multi-fields may be added, as may the first properties of an object or the first
+		// multi-fields of a field
+		addsField := key == "properties" || key == "fields"
+		addsProperties := k == "properties" && (oldObj["type"] == nil || oldObj["type"] == "object" ||
+			oldObj["type"] == "nested")
+		addsMultiFields := k == "fields" && oldObj["type"] != nil
+
+		if !addsField && !addsProperties && !addsMultiFields {
+			return false
+		}
+	}
+
+	return true
+}
+
+// flattenSettings turns nested settings into a map keyed by dotted setting name without the "index." prefix
+func flattenSettings(settings interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	var flatten func(prefix string, value interface{})
+	flatten = func(prefix string, value interface{}) {
+		if obj, ok := value.(map[string]interface{}); ok {
+			for k, v := range obj {
+				if prefix != "" {
+					k = fmt.Sprintf("%v.%v", prefix, k)
+				}
+				flatten(k, v)
+			}
+			return
+		}
+		result[strings.TrimPrefix(prefix, "index.")] = value
+	}
+
+	if settings != nil {
+		flatten("", settings)
+	}
+
+	return result
+}
+
+func isDynamicSetting(key string) bool {
+	for _, s := range dynamicSettings {
+		if key == s {
+			return true
+		}
+	}
+
+	for _, p := range dynamicSettingPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func asObject(value interface{}) map[string]interface{} {
+	if obj, ok := value.(map[string]interface{}); ok {
+		return obj
+	}
+	return map[string]interface{}{}
+}
+
+func unionKeys(m1 map[string]interface{}, m2 map[string]interface{}) []string {
+	keys := make([]string, 0)
+
+	for k := range m1 {
+		keys = append(keys, k)
+	}
+
+	for k := range m2 {
+		if _, ok := m1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"testing"
+)
+
+func Test_classifyIndexChange(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		oldDef   string
+		newDef   string
+		expected indexChange
+	}{
+		{
+			desc:     "unchanged",
+			oldDef:   `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			newDef:   `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			expected: indexChange{compatible: true},
+		},
+		{
+			desc:   "field added",
+			oldDef: `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			newDef: `{"mappings":{"properties":{"a":{"type":"text"},"b":{"type":"keyword"}}}}`,
+			expected: indexChange{
+				compatible: true,
+				mapping:    `{"properties":{"a":{"type":"text"},"b":{"type":"keyword"}}}`,
+			},
+		},
+		{
+			desc:   "multi-field added",
+			oldDef: `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			newDef: `{"mappings":{"properties":{"a":{"type":"text","fields":{"raw":{"type":"keyword"}}}}}}`,
+			expected: indexChange{
+				compatible: true,
+				mapping:    `{"properties":{"a":{"fields":{"raw":{"type":"keyword"}},"type":"text"}}}`,
+			},
+		},
+		{
+			desc:   "properties added to empty mapping",
+			oldDef: `{}`,
+			newDef: `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			expected: indexChange{
+				compatible: true,
+				mapping:    `{"properties":{"a":{"type":"text"}}}`,
+			},
+		},
+		{
+			desc:     "field type changed",
+			oldDef:   `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			newDef:   `{"mappings":{"properties":{"a":{"type":"keyword"}}}}`,
+			expected: indexChange{},
+		},
+		{
+			desc:     "field removed",
+			oldDef:   `{"mappings":{"properties":{"a":{"type":"text"},"b":{"type":"text"}}}}`,
+			newDef:   `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			expected: indexChange{},
+		},
+		{
+			desc:     "field parameter added",
+			oldDef:   `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+			newDef:   `{"mappings":{"properties":{"a":{"type":"text","analyzer":"english"}}}}`,
+			expected: indexChange{},
+		},
+		{
+			desc:   "dynamic settings changed",
+			oldDef: `{"settings":{"number_of_shards":1,"index":{"refresh_interval":"1s"}}}`,
+			newDef: `{"settings":{"index.number_of_shards":1,"refresh_interval":"5s","number_of_replicas":2}}`,
+			expected: indexChange{
+				compatible: true,
+				settings:   `{"index.number_of_replicas":2,"index.refresh_interval":"5s"}`,
+			},
+		},
+		{
+			desc:   "dynamic setting removed",
+			oldDef: `{"settings":{"refresh_interval":"1s"}}`,
+			newDef: `{}`,
+			expected: indexChange{
+				compatible: true,
+				settings:   `{"index.refresh_interval":null}`,
+			},
+		},
+		{
+			desc:     "static setting changed",
+			oldDef:   `{"settings":{"number_of_shards":1}}`,
+			newDef:   `{"settings":{"number_of_shards":2}}`,
+			expected: indexChange{},
+		},
+		{
+			desc:     "analysis changed",
+			oldDef:   `{"settings":{"analysis":{"analyzer":{"x":{"type":"standard"}}}}}`,
+			newDef:   `{"settings":{"analysis":{"analyzer":{"x":{"type":"simple"}}}}}`,
+			expected: indexChange{},
+		},
+		{
+			desc:     "aliases changed",
+			oldDef:   `{}`,
+			newDef:   `{"aliases":{"x":{}}}`,
+			expected: indexChange{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := classifyIndexChange(tc.oldDef, tc.newDef)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tc.expected {
+				t.Errorf("got %+v, want %+v", got, tc.expected)
+			}
+		})
+	}
+}
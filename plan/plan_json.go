@@ -0,0 +1,105 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalPlan renders a plan as JSON, one object per action giving its kind, the resource it was
+// planned for and its fields
+func MarshalPlan(plan []PlanAction) ([]byte, error) {
+	return json.MarshalIndent(plan, "", "  ")
+}
+
+// UnmarshalPlan reads a plan previously rendered by MarshalPlan
+func UnmarshalPlan(data []byte) ([]PlanAction, error) {
+	var raw []json.RawMessage
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("couldn't parse plan: %w", err)
+	}
+
+	plan := make([]PlanAction, 0)
+
+	for i, r := range raw {
+		var a struct {
+			Kind string `json:"kind"`
+		}
+
+		if err := json.Unmarshal(r, &a); err != nil {
+			return nil, fmt.Errorf("couldn't parse plan action %v: %w", i, err)
+		}
+
+		newAction, ok := actionKinds[a.Kind]
+
+		if !ok {
+			return nil, fmt.Errorf("couldn't parse plan action %v: unknown kind %q", i, a.Kind)
+		}
+
+		action := newAction()
+
+		if err := json.Unmarshal(r, action); err != nil {
+			return nil, fmt.Errorf("couldn't parse plan action %v: %w", i, err)
+		}
+
+		plan = append(plan, action)
+	}
+
+	return plan, nil
+}
+
+var actionKinds = map[string]func() PlanAction{
+	"createIndex":         func() PlanAction { return &createIndex{} },
+	"writeChangelogEntry": func() PlanAction { return &writeChangelogEntry{} },
+	"reindex":             func() PlanAction { return &reindex{} },
+	"createAlias":         func() PlanAction { return &createAlias{} },
+	"updateAlias":         func() PlanAction { return &updateAlias{} },
+	"putMapping":          func() PlanAction { return &putMapping{} },
+	"putSettings":         func() PlanAction { return &putSettings{} },
+	"putPipeline":         func() PlanAction { return &putPipeline{} },
+	"indexDocument":       func() PlanAction { return &indexDocument{} },
+	"closeIndex":          func() PlanAction { return &closeIndex{} },
+	"openIndex":           func() PlanAction { return &openIndex{} },
+	"blockWrites":         func() PlanAction { return &blockWrites{} },
+	"unblockWrites":       func() PlanAction { return &unblockWrites{} },
+	"deleteIndex":         func() PlanAction { return &deleteIndex{} },
+	"deletePipeline":      func() PlanAction { return &deletePipeline{} },
+	"deleteDocument":      func() PlanAction { return &deleteDocument{} },
+	"removeAlias":         func() PlanAction { return &removeAlias{} },
+	"writeTombstone":      func() PlanAction { return &writeTombstone{} },
+	"verifyDocCount":      func() PlanAction { return &verifyDocCount{} },
+	"runCheck":            func() PlanAction { return &runCheck{} },
+	"waitForHealth":       func() PlanAction { return &waitForHealth{} },
+	"catchUp":             func() PlanAction { return &catchUp{} },
+}
+
+type actionJson struct {
+	Kind     string      `json:"kind"`
+	Resource Resource    `json:"resource"`
+	Fields   interface{} `json:"fields"`
+}
+
+func marshalAction(kind string, resource Resource, fields interface{}) ([]byte, error) {
+	return json.Marshal(actionJson{
+		Kind:     kind,
+		Resource: resource,
+		Fields:   fields,
+	})
+}
+
+func unmarshalAction(data []byte, fields interface{}) (Resource, error) {
+	var a struct {
+		Resource Resource        `json:"resource"`
+		Fields   json.RawMessage `json:"fields"`
+	}
+
+	if err := json.Unmarshal(data, &a); err != nil {
+		return Resource{}, err
+	}
+
+	if err := json.Unmarshal(a.Fields, fields); err != nil {
+		return Resource{}, err
+	}
+
+	return a.Resource, nil
+}
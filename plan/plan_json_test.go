@@ -0,0 +1,228 @@
+package plan
+
+import (
+	"errors"
+	"github.com/hdpe.me/esup/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalPlan(t *testing.T) {
+	res := Resource{Type: "index_set", Identifier: "x"}
+
+	plan := []PlanAction{
+		&createIndex{
+			name:       "env-x_20010203040506",
+			definition: "{}",
+			resource:   res,
+		},
+		&reindex{
+			from:              "env-x",
+			to:                "env-x_20010203040506",
+			maxDocs:           -1,
+			pipeline:          "env-p",
+			requestsPerSecond: 500,
+			slices:            "auto",
+			batchSize:         100,
+			resource:          res,
+		},
+		&updateAlias{
+			name:            "env-x",
+			indexToAdd:      "env-x_20010203040506",
+			indicesToRemove: []string{"old"},
+			resource:        res,
+		},
+	}
+
+	got, err := MarshalPlan(plan)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[
+  {
+    "kind": "createIndex",
+    "resource": {
+      "type": "index_set",
+      "identifier": "x"
+    },
+    "fields": {
+      "index": "env-x_20010203040506",
+      "definition": "{}"
+    }
+  },
+  {
+    "kind": "reindex",
+    "resource": {
+      "type": "index_set",
+      "identifier": "x"
+    },
+    "fields": {
+      "from": "env-x",
+      "to": "env-x_20010203040506",
+      "maxDocs": -1,
+      "pipeline": "env-p",
+      "requestsPerSecond": 500,
+      "slices": "auto",
+      "batchSize": 100
+    }
+  },
+  {
+    "kind": "updateAlias",
+    "resource": {
+      "type": "index_set",
+      "identifier": "x"
+    },
+    "fields": {
+      "alias": "env-x",
+      "indexToAdd": "env-x_20010203040506",
+      "indicesToRemove": [
+        "old"
+      ]
+    }
+  }
+]`
+
+	if string(got) != want {
+		t.Errorf("got %v, want %v", string(got), want)
+	}
+}
+
+func TestUnmarshalPlan(t *testing.T) {
+	res := Resource{Type: "document", Identifier: "x/y"}
+
+	want := []PlanAction{
+		&putPipeline{
+			id:         "env-p",
+			definition: `{"processors":[]}`,
+			resource:   Resource{Type: "pipeline", Identifier: "p"},
+		},
+		&createAlias{
+			name:     "env-x",
+			index:    "env-x_20010203040506",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&indexDocument{
+			index:    "env-x",
+			id:       "y",
+			document: `{"k":"v"}`,
+			resource: res,
+		},
+		&writeChangelogEntry{
+			resourceType:       "document",
+			resourceIdentifier: "x/y",
+			finalName:          "y",
+			definition:         `{"k":"v"}`,
+			meta:               `{"Ignored":false}`,
+			envName:            "env",
+			resource:           res,
+		},
+		&blockWrites{
+			index:    "env-x_20010203040506",
+			reason:   "superseded 2001-02-03",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&unblockWrites{
+			index:    "env-x_20010203040506",
+			reason:   "rolling back",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&closeIndex{
+			name:     "env-x_20010203040506",
+			reason:   "superseded 2001-02-03",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&openIndex{
+			name:     "env-x_20010203040506",
+			reason:   "rolling back",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&deleteIndex{
+			name:     "env-x_20010203040506",
+			reason:   "superseded 2001-02-03",
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&deleteDocument{
+			index:    "env-x",
+			id:       "y",
+			resource: res,
+		},
+		&removeAlias{
+			name:     "env-x",
+			indices:  []string{"env-x_20010203040506"},
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&deletePipeline{
+			id:       "env-p",
+			resource: Resource{Type: "pipeline", Identifier: "p"},
+		},
+		&writeTombstone{
+			resourceType:       "pipeline",
+			resourceIdentifier: "p",
+			envName:            "env",
+			resource:           Resource{Type: "pipeline", Identifier: "p"},
+		},
+		&verifyDocCount{
+			from:      "env-x",
+			index:     "env-x_20010203040506",
+			maxDocs:   -1,
+			tolerance: 0.5,
+			resource:  Resource{Type: "index_set", Identifier: "x"},
+		},
+		&runCheck{
+			name:  "hits",
+			index: "env-x_20010203040506",
+			check: check{
+				request:     `{"query":{"match_all":{}}}`,
+				minHits:     1,
+				ids:         []string{"a"},
+				aggregation: "y",
+			},
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&waitForHealth{
+			index:    "env-x_20010203040506",
+			status:   "green",
+			timeout:  5 * time.Minute,
+			resource: Resource{Type: "index_set", Identifier: "x"},
+		},
+		&catchUp{
+			from:         "env-x",
+			to:           "env-x_20010203040506",
+			blockIndices: []string{"env-x_20000101000000"},
+			field:        "updatedAt",
+			threshold:    1000,
+			margin:       time.Minute,
+			pipeline:     "env-p",
+			slices:       "auto",
+			batchSize:    100,
+			resource:     Resource{Type: "index_set", Identifier: "x"},
+		},
+	}
+
+	b, err := MarshalPlan(want)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := UnmarshalPlan(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUnmarshalPlan_unknownKind(t *testing.T) {
+	_, err := UnmarshalPlan([]byte(`[{"kind":"other","resource":{},"fields":{}}]`))
+
+	if want := errors.New(`couldn't parse plan action 0: unknown kind "other"`); !testutil.ErrorsEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,150 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/cheggaaa/pb/v3"
+	"io"
+	"sync"
+	"time"
+)
+
+var progressTemplate pb.ProgressBarTemplate = `{{string . "label"}} {{counters . }} {{bar . }} {{percent . }}`
+
+// plainProgressInterval is how often a line is written for each reindex in progress when not in a terminal
+const plainProgressInterval = 30 * time.Second
+
+func newProgress(out io.Writer) *progress {
+	return &progress{out: out, interval: plainProgressInterval}
+}
+
+// progress displays a line with a progress bar for each reindex running at once, redrawing them together in a
+// terminal. Elsewhere a line is written for each reindex at an interval while it runs, and as it finishes.
+type progress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	interval time.Duration
+	active   []*progressBar
+	// drawn is the number of lines for active bars the last draw wrote
+	drawn int
+}
+
+type progressBar struct {
+	bar  *pb.ProgressBar
+	done bool
+	// written is when a line was last written for the bar, when not in a terminal
+	written time.Time
+}
+
+// add adds a bar for a reindex to the display
+func (p *progress) add(label string) *progressBar {
+	bar := progressTemplate.New(0).
+		SetWriter(p.out).
+		Set("label", label)
+
+	b := &progressBar{bar: bar, written: time.Now()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active = append(p.active, b)
+	p.draw()
+
+	return b
+}
+
+// update sets the progress of a bar and redraws the display
+func (p *progress) update(b *progressBar, current int64, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.bar.SetTotal(total)
+	b.bar.SetCurrent(current)
+	p.draw()
+}
+
+// finish draws a bar for the last time and removes it from the display
+func (p *progress) finish(b *progressBar) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.done = true
+	p.draw()
+}
+
+// log writes a line above the active bars, so it isn't overwritten when they're redrawn
+func (p *progress) log(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn == 0 {
+		_, _ = fmt.Fprintln(p.out, line)
+		return
+	}
+
+	// write the line over the first bar and draw all of them again below it
+	_, _ = fmt.Fprintf(p.out, "\x1b[%dA\x1b[2K%v\n", p.drawn, line)
+	p.drawn = 0
+	p.draw()
+}
+
+func (p *progress) draw() {
+	lines := make([]string, 0, len(p.active))
+	terminal := false
+
+	for _, b := range p.active {
+		lines = append(lines, b.bar.String())
+		terminal = b.bar.GetBool(pb.Terminal)
+	}
+
+	if !terminal {
+		p.drawPlain(lines)
+		return
+	}
+
+	// overwrite the lines for the bars active last time, finished bars first so they're left behind above the rest
+	out := ""
+
+	if p.drawn > 0 {
+		out += fmt.Sprintf("\x1b[%dA", p.drawn)
+	}
+
+	active := make([]*progressBar, 0, len(p.active))
+
+	for i, b := range p.active {
+		if b.done {
+			out += fmt.Sprintf("\x1b[2K%v\n", lines[i])
+		}
+	}
+
+	for i, b := range p.active {
+		if !b.done {
+			out += fmt.Sprintf("\x1b[2K%v\n", lines[i])
+			active = append(active, b)
+		}
+	}
+
+	p.active = active
+	p.drawn = len(active)
+
+	_, _ = fmt.Fprint(p.out, out)
+}
+
+func (p *progress) drawPlain(lines []string) {
+	active := make([]*progressBar, 0, len(p.active))
+
+	for i, b := range p.active {
+		if b.done {
+			_, _ = fmt.Fprintln(p.out, lines[i])
+			continue
+		}
+
+		if time.Since(b.written) >= p.interval {
+			_, _ = fmt.Fprintln(p.out, lines[i])
+			b.written = time.Now()
+		}
+
+		active = append(active, b)
+	}
+
+	p.active = active
+}
@@ -0,0 +1,74 @@
+package plan
+
+import (
+	"bytes"
+	"github.com/cheggaaa/pb/v3"
+	"strings"
+	"testing"
+)
+
+func TestProgress_NotTerminal(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out)
+
+	x := p.add("env-x_1")
+	y := p.add("env-y_1")
+
+	p.update(x, 5, 10)
+	p.update(y, 10, 10)
+	p.finish(y)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "env-y_1 10 / 10") {
+		t.Errorf("got output %q, want one line for the finished bar", out.String())
+	}
+
+	// a line is written for a bar still running once the interval has passed
+	out.Reset()
+	x.written = x.written.Add(-p.interval)
+	p.update(x, 6, 10)
+
+	if !strings.HasPrefix(out.String(), "env-x_1 6 / 10") {
+		t.Errorf("got output %q, want a line for the running bar", out.String())
+	}
+
+	out.Reset()
+	p.update(x, 7, 10)
+
+	if out.Len() > 0 {
+		t.Errorf("got output %q, want none before the interval has passed again", out.String())
+	}
+
+	p.finish(x)
+
+	if got, want := len(p.active), 0; got != want {
+		t.Errorf("got %v active bars, want %v", got, want)
+	}
+}
+
+func TestProgress_log(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out)
+
+	p.log("before")
+
+	if got, want := out.String(), "before\n"; got != want {
+		t.Errorf("got output %q, want %q with no bars drawn", got, want)
+	}
+
+	x := p.add("env-x_1")
+	x.bar.Set(pb.Terminal, true)
+	p.update(x, 5, 10)
+
+	out.Reset()
+	p.log("during")
+
+	if want := "\x1b[1A\x1b[2Kduring\n\x1b[2Kenv-x_1 5 / 10"; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got output %q, want the line written over the bar and the bar drawn again below it", out.String())
+	}
+
+	if got, want := p.drawn, 1; got != want {
+		t.Errorf("got %v lines drawn, want %v", got, want)
+	}
+}
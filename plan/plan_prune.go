@@ -0,0 +1,132 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/tidwall/gjson"
+	"strings"
+)
+
+// SetPrune sets whether plans remove resources which esup has managed but which are no longer in the schema
+func (r *Planner) SetPrune(prune bool) {
+	r.prune = prune
+}
+
+// appendPruneMutations plans removing the documents, index set aliases and pipelines in the changelog which are no
+// longer in the schema, and writing tombstone changelog entries for them
+func (r *Planner) appendPruneMutations(plan *[]PlanAction) error {
+	inSchema := make(map[string]bool)
+
+	for _, p := range r.schema.Pipelines {
+		inSchema[resourceKey(Resource{Type: "pipeline", Identifier: p.Name})] = true
+	}
+
+	for _, is := range r.schema.IndexSets {
+		inSchema[resourceKey(Resource{Type: "index_set", Identifier: is.ResourceIdentifier()})] = true
+	}
+
+	for _, doc := range r.schema.Documents {
+		inSchema[resourceKey(Resource{Type: "document", Identifier: doc.ResourceIdentifier()})] = true
+	}
+
+	entries, err := r.changelog.GetLatestChangelogEntries(r.envName)
+
+	if err != nil {
+		return fmt.Errorf("couldn't get changelog entries: %w", err)
+	}
+
+	r.snapshot.recordLatestChangelogEntries(entries)
+
+	removed := func(resourceType string) []es.ChangelogEntry {
+		result := make([]es.ChangelogEntry, 0)
+
+		for _, entry := range entries {
+			res := Resource{Type: resourceType, Identifier: entry.ResourceIdentifier}
+
+			if entry.ResourceType == resourceType && !entry.Deleted && !inSchema[resourceKey(res)] {
+				result = append(result, entry)
+			}
+		}
+
+		return result
+	}
+
+	// documents go first, while the aliases they were indexed through still exist
+	for _, entry := range removed("document") {
+		res := Resource{Type: "document", Identifier: entry.ResourceIdentifier}
+
+		if !gjson.Get(entry.Meta, "Ignored").Bool() {
+			index := newAliasName(strings.SplitN(entry.ResourceIdentifier, "/", 2)[0], r.envName)
+			live, err := r.es.GetDocument(index, entry.FinalName)
+
+			if err != nil {
+				return fmt.Errorf("couldn't get document %v/%v: %w", index, entry.FinalName, err)
+			}
+
+			r.snapshot.recordDocument(index, entry.FinalName, live.IsPresent())
+
+			if live.IsPresent() {
+				*plan = append(*plan, &deleteDocument{
+					index:    index,
+					id:       entry.FinalName,
+					resource: res,
+				})
+			}
+		}
+
+		*plan = append(*plan, r.tombstone(res))
+	}
+
+	for _, entry := range removed("index_set") {
+		res := Resource{Type: "index_set", Identifier: entry.ResourceIdentifier}
+		aliasName := newAliasName(entry.ResourceIdentifier, r.envName)
+		indices, err := r.es.GetIndicesForAlias(aliasName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get alias %v: %w", aliasName, err)
+		}
+
+		r.snapshot.recordAlias(aliasName, indices)
+
+		if len(indices) > 0 {
+			*plan = append(*plan, &removeAlias{
+				name:     aliasName,
+				indices:  sortedIndices(indices),
+				resource: res,
+			})
+		}
+
+		*plan = append(*plan, r.tombstone(res))
+	}
+
+	for _, entry := range removed("pipeline") {
+		res := Resource{Type: "pipeline", Identifier: entry.ResourceIdentifier}
+		live, err := r.es.GetPipelineDef(entry.FinalName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get pipeline %v: %w", entry.FinalName, err)
+		}
+
+		r.snapshot.recordPipeline(entry.FinalName, live)
+
+		if live != "" {
+			*plan = append(*plan, &deletePipeline{
+				id:       entry.FinalName,
+				resource: res,
+			})
+		}
+
+		*plan = append(*plan, r.tombstone(res))
+	}
+
+	return nil
+}
+
+func (r *Planner) tombstone(res Resource) *writeTombstone {
+	return &writeTombstone{
+		resourceType:       res.Type,
+		resourceIdentifier: res.Identifier,
+		envName:            r.envName,
+		resource:           res,
+	}
+}
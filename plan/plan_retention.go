@@ -0,0 +1,190 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/schema"
+	"sort"
+	"strings"
+	"time"
+)
+
+// supersededIndex is an index which was once behind an index set's alias but no longer is
+type supersededIndex struct {
+	name  string
+	since time.Time
+}
+
+// appendRetentionMutations plans each index set's retention action for those of its superseded indices which
+// are due it, taking account of alias changes already in the plan
+func (r *Planner) appendRetentionMutations(plan *[]PlanAction) error {
+	for _, is := range r.schema.IndexSets {
+		retention := is.Meta.Retention
+
+		if is.Meta.Index != "" || retention.Action == "" {
+			continue
+		}
+
+		aliasName := newAliasName(is.IndexSet, r.envName)
+		aliased, err := r.es.GetIndicesForAlias(aliasName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get alias %v: %w", aliasName, err)
+		}
+
+		r.snapshot.recordAlias(aliasName, aliased)
+
+		for _, item := range *plan {
+			if a, ok := item.(*updateAlias); ok && a.name == aliasName {
+				aliased = []string{a.indexToAdd}
+			} else if a, ok := item.(*createAlias); ok && a.name == aliasName {
+				aliased = []string{a.index}
+			}
+		}
+
+		entries, err := r.changelog.GetChangelogEntries("index_set", is.ResourceIdentifier(), r.envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog history for %v: %w", is.ResourceIdentifier(), err)
+		}
+
+		r.snapshot.recordHistory("index_set", is.ResourceIdentifier(), entries)
+
+		now := r.clock.Now().UTC()
+		due, err := dueForRetention(entries, aliased, newIndexName(is.IndexSet, r.envName, ""), retention, now)
+
+		if err != nil {
+			return fmt.Errorf("couldn't apply retention to %v: %w", is.ResourceIdentifier(), err)
+		}
+
+		res := Resource{Type: "index_set", Identifier: is.ResourceIdentifier()}
+
+		for _, index := range due {
+			action, err := r.retentionAction(index, retention.Action, res, now)
+
+			if err != nil {
+				return fmt.Errorf("couldn't apply retention to %v: %w", index.name, err)
+			}
+
+			if action != nil {
+				*plan = append(*plan, action)
+			}
+		}
+	}
+
+	return nil
+}
+
+// retentionAction returns the action to take on a superseded index, or nil if it has already been taken
+func (r *Planner) retentionAction(index supersededIndex, action string, res Resource,
+	now time.Time) (PlanAction, error) {
+
+	indices, err := r.es.GetIndices(index.name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.snapshot.recordIndex(index.name, indices)
+
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	closed := indices[0].Status == "close"
+	reason := fmt.Sprintf("superseded %v days ago", int(now.Sub(index.since).Hours()/24))
+
+	switch action {
+	case schema.RetentionActionClose:
+		if closed {
+			return nil, nil
+		}
+		return &closeIndex{name: index.name, reason: reason, resource: res}, nil
+	case schema.RetentionActionReadOnly:
+		if closed {
+			return nil, nil
+		}
+
+		blocked, err := r.es.GetIndexSetting(index.name, "index.blocks.write")
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.snapshot.recordWriteBlock(index.name, blocked)
+
+		if blocked == "true" {
+			return nil, nil
+		}
+		return &blockWrites{index: index.name, reason: reason, resource: res}, nil
+	case schema.RetentionActionDelete:
+		return &deleteIndex{name: index.name, reason: reason, resource: res}, nil
+	}
+
+	return nil, fmt.Errorf("unknown retention action %q", action)
+}
+
+// dueForRetention works out from an index set's changelog history when each of its indices was superseded, and
+// returns those beyond the most recent retention.Keep which were superseded at least retention.AfterDays ago. An
+// index still in the changelog but no longer behind the alias is taken to have been superseded now. Only indices
+// named with indexPrefix are considered, so static indices are never touched.
+func dueForRetention(entries []es.ChangelogEntry, aliased []string, indexPrefix string,
+	retention schema.IndexSetMetaRetention, now time.Time) ([]supersededIndex, error) {
+
+	isAliased := make(map[string]bool)
+
+	for _, index := range aliased {
+		isAliased[index] = true
+	}
+
+	supersededSince := make(map[string]time.Time)
+	current := ""
+
+	for _, entry := range entries {
+		if entry.FinalName == current {
+			continue
+		}
+
+		t, err := entry.Time()
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse changelog timestamp %q: %w", entry.Timestamp, err)
+		}
+
+		if current != "" {
+			supersededSince[current] = t
+		}
+
+		delete(supersededSince, entry.FinalName)
+		current = entry.FinalName
+	}
+
+	if current != "" && !isAliased[current] {
+		supersededSince[current] = now
+	}
+
+	superseded := make([]supersededIndex, 0)
+
+	for name, since := range supersededSince {
+		if !isAliased[name] && strings.HasPrefix(name, indexPrefix) {
+			superseded = append(superseded, supersededIndex{name: name, since: since})
+		}
+	}
+
+	sort.Slice(superseded, func(i, j int) bool {
+		if !superseded[i].since.Equal(superseded[j].since) {
+			return superseded[i].since.After(superseded[j].since)
+		}
+		return superseded[i].name > superseded[j].name
+	})
+
+	due := make([]supersededIndex, 0)
+
+	for i, index := range superseded {
+		if i >= retention.Keep && now.Sub(index.since) >= time.Duration(retention.AfterDays)*24*time.Hour {
+			due = append(due, index)
+		}
+	}
+
+	return due, nil
+}
@@ -0,0 +1,96 @@
+package plan
+
+import (
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/schema"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_dueForRetention(t *testing.T) {
+	entries := []es.ChangelogEntry{
+		{FinalName: "dev-x_1", Timestamp: "2020-01-01T00:00:00.000"},
+		{FinalName: "dev-x_2", Timestamp: "2020-01-10T00:00:00.000"},
+		{FinalName: "dev-x_2", Timestamp: "2020-01-11T00:00:00.000"},
+		{FinalName: "dev-x_3", Timestamp: "2020-01-20T00:00:00.000"},
+		{FinalName: "dev-x_4", Timestamp: "2020-01-30T00:00:00.000"},
+	}
+
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc      string
+		entries   []es.ChangelogEntry
+		aliased   []string
+		retention schema.IndexSetMetaRetention
+		want      []string
+	}{
+		{
+			desc:      "all superseded indices",
+			entries:   entries,
+			aliased:   []string{"dev-x_4"},
+			retention: schema.IndexSetMetaRetention{Action: "delete"},
+			want:      []string{"dev-x_3", "dev-x_2", "dev-x_1"},
+		},
+		{
+			desc:      "keeps most recently superseded",
+			entries:   entries,
+			aliased:   []string{"dev-x_4"},
+			retention: schema.IndexSetMetaRetention{Action: "delete", Keep: 1},
+			want:      []string{"dev-x_2", "dev-x_1"},
+		},
+		{
+			desc:      "only after days since superseded",
+			entries:   entries,
+			aliased:   []string{"dev-x_4"},
+			retention: schema.IndexSetMetaRetention{Action: "delete", AfterDays: 20},
+			want:      []string{"dev-x_1"},
+		},
+		{
+			desc:      "index being superseded now",
+			entries:   entries,
+			aliased:   []string{"dev-x_5"},
+			retention: schema.IndexSetMetaRetention{Action: "delete", Keep: 3},
+			want:      []string{"dev-x_1"},
+		},
+		{
+			desc: "index rolled back to",
+			entries: append(entries, es.ChangelogEntry{FinalName: "dev-x_3",
+				Timestamp: "2020-01-31T00:00:00.000"}),
+			aliased:   []string{"dev-x_3"},
+			retention: schema.IndexSetMetaRetention{Action: "delete"},
+			want:      []string{"dev-x_4", "dev-x_2", "dev-x_1"},
+		},
+		{
+			desc: "static index",
+			entries: []es.ChangelogEntry{
+				{FinalName: "static", Timestamp: "2020-01-01T00:00:00.000"},
+				{FinalName: "dev-x_1", Timestamp: "2020-01-10T00:00:00.000"},
+			},
+			aliased:   []string{"dev-x_1"},
+			retention: schema.IndexSetMetaRetention{Action: "delete"},
+			want:      []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			due, err := dueForRetention(tc.entries, tc.aliased, "dev-x_", tc.retention, now)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0)
+
+			for _, index := range due {
+				got = append(got, index.name)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
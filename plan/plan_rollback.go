@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+)
+
+// PlanRollback plans repointing an index set's alias back to the index of the most recent earlier changelog
+// entry whose index still exists, and restoring that entry's content and meta as the changelog baseline
+func (r *Planner) PlanRollback(indexSet string) ([]PlanAction, error) {
+	is, err := r.schema.GetIndexSet(indexSet)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if is.Meta.Index != "" {
+		return nil, fmt.Errorf("can't roll back %v: it uses static index %v", indexSet, is.Meta.Index)
+	}
+
+	entries, err := r.changelog.GetChangelogEntries("index_set", is.ResourceIdentifier(), r.envName)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get changelog history for %v: %w", is.ResourceIdentifier(), err)
+	}
+
+	aliasName := newAliasName(is.IndexSet, r.envName)
+	existingIndices, err := r.es.GetIndicesForAlias(aliasName)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get alias %v: %w", aliasName, err)
+	}
+
+	if existingIndices == nil {
+		return nil, fmt.Errorf("can't roll back %v: alias %v doesn't exist", indexSet, aliasName)
+	}
+
+	statuses := make(map[string]string)
+
+	target, err := rollbackTarget(entries, existingIndices, func(index string) (bool, error) {
+		indices, err := r.es.GetIndices(index)
+
+		if err != nil {
+			return false, err
+		}
+
+		if len(indices) == 1 {
+			statuses[index] = indices[0].Status
+		}
+
+		return len(indices) == 1, nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("can't roll back %v: %w", indexSet, err)
+	}
+
+	res := Resource{Type: "index_set", Identifier: is.ResourceIdentifier()}
+	plan := make([]PlanAction, 0)
+
+	// the target may have been closed by a retention policy
+	if statuses[target.FinalName] != "open" {
+		plan = append(plan, &openIndex{
+			name:     target.FinalName,
+			reason:   "rolling back",
+			resource: res,
+		})
+	}
+
+	// writes to the target may have been blocked while reindexing from it, or by a retention policy
+	blocked, err := r.es.GetIndexSetting(target.FinalName, "index.blocks.write")
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get write block of %v: %w", target.FinalName, err)
+	}
+
+	if blocked == "true" {
+		plan = append(plan, &unblockWrites{
+			index:    target.FinalName,
+			reason:   "rolling back",
+			resource: res,
+		})
+	}
+
+	return append(plan,
+		&updateAlias{
+			name:            aliasName,
+			indexToAdd:      target.FinalName,
+			indicesToRemove: existingIndices,
+			resource:        res,
+		},
+		&writeChangelogEntry{
+			resourceType:       "index_set",
+			resourceIdentifier: is.ResourceIdentifier(),
+			finalName:          target.FinalName,
+			definition:         target.Content,
+			meta:               target.Meta,
+			envName:            r.envName,
+			resource:           res,
+		},
+	), nil
+}
+
+// rollbackTarget returns the newest changelog entry, written before the index currently behind the alias was
+// first recorded, for an index which still exists. Repeated rollbacks therefore step further back in history.
+func rollbackTarget(entries []es.ChangelogEntry, aliasIndices []string,
+	indexExists func(index string) (bool, error)) (es.ChangelogEntry, error) {
+
+	current := make(map[string]bool)
+
+	for _, index := range aliasIndices {
+		current[index] = true
+	}
+
+	start := len(entries)
+
+	for i, entry := range entries {
+		if current[entry.FinalName] {
+			start = i
+			break
+		}
+	}
+
+	for i := start - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		if entry.FinalName == "" || current[entry.FinalName] {
+			continue
+		}
+
+		exists, err := indexExists(entry.FinalName)
+
+		if err != nil {
+			return es.ChangelogEntry{}, err
+		}
+
+		if exists {
+			return entry, nil
+		}
+	}
+
+	return es.ChangelogEntry{}, fmt.Errorf("no earlier index in changelog still exists")
+}
@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"testing"
+)
+
+func Test_rollbackTarget(t *testing.T) {
+	entries := []es.ChangelogEntry{
+		{FinalName: "dev-i_1", Content: "1"},
+		{FinalName: "dev-i_2", Content: "2"},
+		{FinalName: "dev-i_3", Content: "3"},
+		{FinalName: "dev-i_3", Content: "3a"},
+	}
+
+	rolledBack := append(entries, es.ChangelogEntry{FinalName: "dev-i_2", Content: "2"})
+
+	testCases := []struct {
+		desc         string
+		entries      []es.ChangelogEntry
+		aliasIndices []string
+		existing     []string
+		wantContent  string
+		wantErr      bool
+	}{
+		{
+			desc:         "previous index exists",
+			entries:      entries,
+			aliasIndices: []string{"dev-i_3"},
+			existing:     []string{"dev-i_1", "dev-i_2", "dev-i_3"},
+			wantContent:  "2",
+		},
+		{
+			desc:         "previous index deleted",
+			entries:      entries,
+			aliasIndices: []string{"dev-i_3"},
+			existing:     []string{"dev-i_1", "dev-i_3"},
+			wantContent:  "1",
+		},
+		{
+			desc:         "already rolled back",
+			entries:      rolledBack,
+			aliasIndices: []string{"dev-i_2"},
+			existing:     []string{"dev-i_1", "dev-i_2", "dev-i_3"},
+			wantContent:  "1",
+		},
+		{
+			desc:         "no earlier index exists",
+			entries:      entries,
+			aliasIndices: []string{"dev-i_3"},
+			existing:     []string{"dev-i_3"},
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := rollbackTarget(tc.entries, tc.aliasIndices, func(index string) (bool, error) {
+				for _, e := range tc.existing {
+					if e == index {
+						return true, nil
+					}
+				}
+				return false, nil
+			})
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Content != tc.wantContent {
+				t.Errorf("got content %q, want %q", got.Content, tc.wantContent)
+			}
+		})
+	}
+
+	t.Run("index lookup fails", func(t *testing.T) {
+		_, err := rollbackTarget(entries, []string{"dev-i_3"}, func(index string) (bool, error) {
+			return false, fmt.Errorf("boom")
+		})
+
+		if err == nil {
+			t.Errorf("wanted error")
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+	"github.com/hdpe.me/esup/resource"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SavedPlan is a plan persisted to be applied later, along with the cluster state it was planned against
+type SavedPlan struct {
+	Server   string       `json:"server"`
+	EnvName  string       `json:"envName"`
+	Version  string       `json:"version"`
+	Snapshot Snapshot     `json:"snapshot"`
+	Actions  []PlanAction `json:"actions"`
+}
+
+func (p *SavedPlan) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Server   string          `json:"server"`
+		EnvName  string          `json:"envName"`
+		Version  string          `json:"version"`
+		Snapshot Snapshot        `json:"snapshot"`
+		Actions  json.RawMessage `json:"actions"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	actions, err := UnmarshalPlan(raw.Actions)
+
+	if err != nil {
+		return err
+	}
+
+	*p = SavedPlan{
+		Server:   raw.Server,
+		EnvName:  raw.EnvName,
+		Version:  raw.Version,
+		Snapshot: raw.Snapshot,
+		Actions:  actions,
+	}
+
+	return nil
+}
+
+// Snapshot records the cluster state read by the planner
+type Snapshot struct {
+	Aliases   map[string][]string `json:"aliases"`
+	Pipelines map[string]string   `json:"pipelines"`
+	Changelog []SnapshotEntry     `json:"changelog"`
+	// History is the changelog history of each index set read in planning retention
+	History []SnapshotHistory `json:"history"`
+	// Indices are the statuses of indices read, or "" for indices which didn't exist
+	Indices map[string]string `json:"indices"`
+	// WriteBlocks are the index.blocks.write settings of indices read
+	WriteBlocks map[string]string `json:"writeBlocks"`
+	// Latest is the latest changelog entry for each resource in the environment, read if the plan prunes
+	Latest *[]string `json:"latest,omitempty"`
+	// Documents are whether the documents read, keyed by index and ID, existed
+	Documents map[string]bool `json:"documents"`
+}
+
+type SnapshotEntry struct {
+	ResourceType       string `json:"resourceType"`
+	ResourceIdentifier string `json:"resourceIdentifier"`
+	IsPresent          bool   `json:"isPresent"`
+	Content            string `json:"content"`
+	Meta               string `json:"meta"`
+}
+
+// SnapshotHistory is a resource's changelog history, as the final name and timestamp of each entry
+type SnapshotHistory struct {
+	ResourceType       string   `json:"resourceType"`
+	ResourceIdentifier string   `json:"resourceIdentifier"`
+	Entries            []string `json:"entries"`
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		Aliases:     map[string][]string{},
+		Pipelines:   map[string]string{},
+		Changelog:   []SnapshotEntry{},
+		History:     []SnapshotHistory{},
+		Indices:     map[string]string{},
+		WriteBlocks: map[string]string{},
+		Documents:   map[string]bool{},
+	}
+}
+
+func (s *Snapshot) recordAlias(alias string, indices []string) {
+	s.Aliases[alias] = sortedIndices(indices)
+}
+
+func (s *Snapshot) recordPipeline(id string, definition string) {
+	s.Pipelines[id] = definition
+}
+
+func (s *Snapshot) recordChangelogEntry(resourceType string, resourceIdentifier string, entry es.ChangelogEntry) {
+	s.Changelog = append(s.Changelog, SnapshotEntry{
+		ResourceType:       resourceType,
+		ResourceIdentifier: resourceIdentifier,
+		IsPresent:          entry.IsPresent,
+		Content:            entry.Content,
+		Meta:               entry.Meta,
+	})
+}
+
+func (s *Snapshot) recordHistory(resourceType string, resourceIdentifier string, entries []es.ChangelogEntry) {
+	s.History = append(s.History, SnapshotHistory{
+		ResourceType:       resourceType,
+		ResourceIdentifier: resourceIdentifier,
+		Entries:            historyEntries(entries),
+	})
+}
+
+func (s *Snapshot) recordIndex(index string, indices []es.IndexInfo) {
+	s.Indices[index] = indexStatus(indices)
+}
+
+func (s *Snapshot) recordWriteBlock(index string, blocked string) {
+	s.WriteBlocks[index] = blocked
+}
+
+func (s *Snapshot) recordLatestChangelogEntries(entries []es.ChangelogEntry) {
+	latest := latestEntries(entries)
+	s.Latest = &latest
+}
+
+func (s *Snapshot) recordDocument(index string, id string, isPresent bool) {
+	s.Documents[fmt.Sprintf("%v/%v", index, id)] = isPresent
+}
+
+// Verify returns an error describing each difference between the snapshot and the current cluster state
+func (s Snapshot) Verify(es *es.Client, changelog *resource.Changelog, envName string) error {
+	msgs := make([]string, 0)
+
+	for alias, wantIndices := range s.Aliases {
+		indices, err := es.GetIndicesForAlias(alias)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get alias %v: %w", alias, err)
+		}
+
+		if got := sortedIndices(indices); !reflect.DeepEqual(got, wantIndices) {
+			msgs = append(msgs, fmt.Sprintf("alias %v points to %v, not %v", alias, got, wantIndices))
+		}
+	}
+
+	for id, wantDef := range s.Pipelines {
+		def, err := es.GetPipelineDef(id)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get pipeline %v: %w", id, err)
+		}
+
+		if def != wantDef {
+			msgs = append(msgs, fmt.Sprintf("pipeline %v has changed", id))
+		}
+	}
+
+	for _, want := range s.Changelog {
+		entry, err := changelog.GetCurrentChangelogEntry(want.ResourceType, want.ResourceIdentifier, envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entry for %v: %w", want.ResourceIdentifier, err)
+		}
+
+		if entry.IsPresent != want.IsPresent || entry.Content != want.Content || entry.Meta != want.Meta {
+			msgs = append(msgs, fmt.Sprintf("changelog entry for %v %v has changed", want.ResourceType,
+				want.ResourceIdentifier))
+		}
+	}
+
+	for _, want := range s.History {
+		entries, err := changelog.GetChangelogEntries(want.ResourceType, want.ResourceIdentifier, envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog history for %v: %w", want.ResourceIdentifier, err)
+		}
+
+		if got := historyEntries(entries); !reflect.DeepEqual(got, want.Entries) {
+			msgs = append(msgs, fmt.Sprintf("changelog history for %v %v has changed", want.ResourceType,
+				want.ResourceIdentifier))
+		}
+	}
+
+	for index, wantStatus := range s.Indices {
+		indices, err := es.GetIndices(index)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get index %v: %w", index, err)
+		}
+
+		if got := indexStatus(indices); got != wantStatus {
+			msgs = append(msgs, fmt.Sprintf("index %v is %q, not %q", index, got, wantStatus))
+		}
+	}
+
+	for index, wantBlocked := range s.WriteBlocks {
+		blocked, err := es.GetIndexSetting(index, "index.blocks.write")
+
+		if err != nil {
+			return fmt.Errorf("couldn't get write block of %v: %w", index, err)
+		}
+
+		if blocked != wantBlocked {
+			msgs = append(msgs, fmt.Sprintf("write block of index %v has changed", index))
+		}
+	}
+
+	if s.Latest != nil {
+		entries, err := changelog.GetLatestChangelogEntries(envName)
+
+		if err != nil {
+			return fmt.Errorf("couldn't get changelog entries: %w", err)
+		}
+
+		if got := latestEntries(entries); !reflect.DeepEqual(got, *s.Latest) {
+			msgs = append(msgs, "changelog entries have changed")
+		}
+	}
+
+	for key, wantPresent := range s.Documents {
+		// index names can't contain a slash, but IDs can
+		parts := strings.SplitN(key, "/", 2)
+		doc, err := es.GetDocument(parts[0], parts[1])
+
+		if err != nil {
+			return fmt.Errorf("couldn't get document %v: %w", key, err)
+		}
+
+		if doc.IsPresent() != wantPresent {
+			msgs = append(msgs, fmt.Sprintf("document %v has been added or deleted", key))
+		}
+	}
+
+	if len(msgs) > 0 {
+		sort.Strings(msgs)
+		return fmt.Errorf("cluster has changed since plan was made: %v", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
+
+func sortedIndices(indices []string) []string {
+	if indices == nil {
+		return nil
+	}
+
+	sorted := append([]string{}, indices...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func historyEntries(entries []es.ChangelogEntry) []string {
+	result := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		result = append(result, fmt.Sprintf("%v@%v", entry.FinalName, entry.Timestamp))
+	}
+
+	return result
+}
+
+func indexStatus(indices []es.IndexInfo) string {
+	if len(indices) == 0 {
+		return ""
+	}
+
+	return indices[0].Status
+}
+
+func latestEntries(entries []es.ChangelogEntry) []string {
+	result := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		result = append(result, fmt.Sprintf("%v:%v@%v", entry.ResourceType, entry.ResourceIdentifier,
+			entry.Timestamp))
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,146 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	StateUpToDate       = "up to date"
+	StateChangedLocally = "changed locally"
+	StateMissing        = "missing from cluster"
+)
+
+// ResourceStatus describes a resource in the schema as it is in Elasticsearch and the changelog
+type ResourceStatus struct {
+	Resource Resource
+	// Name is the resource's alias, pipeline ID or document ID in Elasticsearch
+	Name    string
+	Indices []string
+	// DocsCount and StoreSize are totals across Indices
+	DocsCount          int64
+	StoreSize          int64
+	ChangelogTimestamp string
+	ChangelogFinalName string
+	State              string
+}
+
+// Status describes every resource in the schema
+func (r *Planner) Status() ([]ResourceStatus, error) {
+	// superseded indices due retention don't make an index set out of date
+	plan, err := r.planResources()
+
+	if err != nil {
+		return nil, err
+	}
+
+	planned := make(map[string]bool)
+
+	for _, item := range plan {
+		planned[resourceKey(item.Resource())] = true
+	}
+
+	stateOf := func(res Resource, present bool) string {
+		if !present {
+			return StateMissing
+		} else if planned[resourceKey(res)] {
+			return StateChangedLocally
+		}
+		return StateUpToDate
+	}
+
+	result := make([]ResourceStatus, 0)
+
+	for _, p := range r.schema.Pipelines {
+		res := Resource{Type: "pipeline", Identifier: p.Name}
+		pipelineId := newPipelineId(p.Name, r.envName)
+
+		def, err := r.es.GetPipelineDef(pipelineId)
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get pipeline %v: %w", pipelineId, err)
+		}
+
+		result = append(result, ResourceStatus{
+			Resource: res,
+			Name:     pipelineId,
+			State:    stateOf(res, def != ""),
+		})
+	}
+
+	for _, is := range r.schema.IndexSets {
+		res := Resource{Type: "index_set", Identifier: is.ResourceIdentifier()}
+		aliasName := newAliasName(is.IndexSet, r.envName)
+
+		status := ResourceStatus{
+			Resource: res,
+			Name:     aliasName,
+			Indices:  []string{},
+		}
+
+		indices, err := r.es.GetIndices(aliasName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, index := range indices {
+			status.Indices = append(status.Indices, index.Name)
+			status.DocsCount += index.DocsCount
+			status.StoreSize += index.StoreSize
+		}
+
+		sort.Strings(status.Indices)
+
+		if err = r.addChangelogStatus(&status); err != nil {
+			return nil, err
+		}
+
+		status.State = stateOf(res, len(indices) > 0)
+		result = append(result, status)
+	}
+
+	for _, doc := range r.schema.Documents {
+		res := Resource{Type: "document", Identifier: doc.ResourceIdentifier()}
+		index := newAliasName(doc.IndexSet, r.envName)
+
+		status := ResourceStatus{
+			Resource: res,
+			Name:     doc.Name,
+			Indices:  []string{index},
+		}
+
+		live, err := r.es.GetDocument(index, doc.Name)
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get document %v/%v: %w", index, doc.Name, err)
+		}
+
+		if err = r.addChangelogStatus(&status); err != nil {
+			return nil, err
+		}
+
+		status.State = stateOf(res, live.IsPresent() || doc.Meta.Ignored)
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+func (r *Planner) addChangelogStatus(status *ResourceStatus) error {
+	res := status.Resource
+	entry, err := r.changelog.GetCurrentChangelogEntry(res.Type, res.Identifier, r.envName)
+
+	if err != nil {
+		return fmt.Errorf("couldn't get changelog entry for %v: %w", res.Identifier, err)
+	}
+
+	status.ChangelogTimestamp = entry.Timestamp
+	status.ChangelogFinalName = entry.FinalName
+
+	return nil
+}
+
+func resourceKey(res Resource) string {
+	return fmt.Sprintf("%v:%v", res.Type, res.Identifier)
+}
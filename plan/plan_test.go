@@ -28,6 +28,9 @@ func TestPlanner_Plan(t *testing.T) {
 	for _, tc := range documentTestCases {
 		testCases = append(testCases, tc)
 	}
+	for _, tc := range pipelineTestCases {
+		testCases = append(testCases, tc)
+	}
 
 	c, err := NewElasticsearchContainer()
 
@@ -78,6 +81,7 @@ func TestPlanner_Plan(t *testing.T) {
 			}
 
 			p := NewPlanner(ctx.Es, ctx.Conf, ctx.Changelog, s, ctx.Proc, tc.Version())
+			p.SetPrune(tc.Prune())
 
 			plan, err := p.Plan()
 
@@ -126,7 +130,8 @@ func GetContext(c *ElasticsearchContainer, s schema.Schema) (*esupContext.Contex
 			Address: baseUrl,
 		},
 		Changelog: config.ChangelogConfig{
-			Index: "changelog",
+			Index:        "changelog",
+			JournalIndex: "journal",
 		},
 	}
 
@@ -137,6 +142,7 @@ func GetContext(c *ElasticsearchContainer, s schema.Schema) (*esupContext.Contex
 	}
 
 	changelog := resource.NewChangelog(conf.Changelog, client)
+	journal := resource.NewJournal(conf.Changelog, client)
 	proc := resource.NewPreprocessor(conf.Preprocess)
 
 	return &esupContext.Context{
@@ -144,6 +150,7 @@ func GetContext(c *ElasticsearchContainer, s schema.Schema) (*esupContext.Contex
 		Schema:    s,
 		Es:        client,
 		Changelog: changelog,
+		Journal:   journal,
 		Proc:      proc,
 	}, nil
 }
@@ -82,6 +82,10 @@ func (r *documentTestCase) Version() string {
 	return r.version
 }
 
+func (r *documentTestCase) Prune() bool {
+	return false
+}
+
 func (r *documentTestCase) Schema() (schema.Schema, error) {
 	var err error
 	r.isFilePath, err = writeTempFile(r.indexSet.Content)
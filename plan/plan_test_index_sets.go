@@ -5,6 +5,7 @@ import (
 	"github.com/hdpe.me/esup/testutil"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 var indexSetTestCases = []PlanTestCase{
@@ -33,6 +34,26 @@ var indexSetTestCases = []PlanTestCase{
 				withMeta("{\"Index\":\"\",\"Prototype\":{\"Disabled\":false,\"MaxDocs\":0},\"Reindex\":{\"Pipeline\":\"\"}}"),
 		},
 	},
+	&indexSetTestCase{
+		desc:    "create fresh index set waiting for health",
+		envName: "env",
+		version: "20010203040506",
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: "{}",
+			Meta: schema.IndexSetMeta{
+				Health: schema.IndexSetMetaHealth{Status: "green", Timeout: time.Minute},
+			},
+		},
+		expected: []testutil.Matcher{
+			newCreateIndexMatcher(),
+			newWaitForHealthMatcher().
+				withIndex("env-x_20010203040506").
+				withStatus("green"),
+			newCreateAliasMatcher(),
+			newWriteChangelogEntryMatcher(),
+		},
+	},
 	&indexSetTestCase{
 		desc:    "update existing index set",
 		envName: "env",
@@ -76,6 +97,205 @@ var indexSetTestCases = []PlanTestCase{
 			newWriteChangelogEntryMatcher(),
 		},
 	},
+	&indexSetTestCase{
+		desc:    "update existing index set deleting superseded index",
+		envName: "env",
+		version: "20010203040506",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&createIndex{
+					name:       "env-x_1",
+					definition: "{}",
+				},
+				&createAlias{
+					name:  "env-x",
+					index: "env-x_1",
+				},
+				&writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: "x",
+					finalName:          "env-x_1",
+					definition:         "{}",
+					meta:               "{}",
+					envName:            "env",
+				},
+			)
+		},
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: "{}",
+			Meta: schema.IndexSetMeta{
+				Retention: schema.IndexSetMetaRetention{Action: "delete"},
+			},
+		},
+		expected: []testutil.Matcher{
+			newCreateIndexMatcher(),
+			newReindexMatcher(),
+			newUpdateAliasMatcher(),
+			newWriteChangelogEntryMatcher(),
+			newDeleteIndexMatcher().
+				withName("env-x_1"),
+		},
+	},
+	&indexSetTestCase{
+		desc:    "update existing index set verifying document count",
+		envName: "env",
+		version: "20010203040506",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&createIndex{
+					name:       "old",
+					definition: "{}",
+				},
+				&createAlias{
+					name:  "env-x",
+					index: "old",
+				},
+				&writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: "x",
+					definition:         "{}",
+					meta:               "{}",
+					envName:            "env",
+				},
+			)
+		},
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: "{}",
+			Meta: schema.IndexSetMeta{
+				Verify: schema.IndexSetMetaVerify{DocCount: true},
+			},
+		},
+		expected: []testutil.Matcher{
+			newCreateIndexMatcher(),
+			newReindexMatcher(),
+			newVerifyDocCountMatcher().
+				withFrom("env-x").
+				withIndex("env-x_20010203040506"),
+			newUpdateAliasMatcher(),
+			newWriteChangelogEntryMatcher(),
+		},
+	},
+	&indexSetTestCase{
+		desc:    "update existing index set blocking writes while reindexing",
+		envName: "env",
+		version: "20010203040506",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&createIndex{
+					name:       "old",
+					definition: "{}",
+				},
+				&createAlias{
+					name:  "env-x",
+					index: "old",
+				},
+				&writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: "x",
+					definition:         "{}",
+					meta:               "{}",
+					envName:            "env",
+				},
+			)
+		},
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: "{}",
+			Meta: schema.IndexSetMeta{
+				Reindex: schema.IndexSetMetaReindex{BlockWrites: true},
+			},
+		},
+		expected: []testutil.Matcher{
+			newCreateIndexMatcher(),
+			newBlockWritesMatcher().
+				withIndex("old"),
+			newReindexMatcher(),
+			newUpdateAliasMatcher(),
+			newWriteChangelogEntryMatcher(),
+		},
+	},
+	&indexSetTestCase{
+		desc:    "update existing index set catching up writes",
+		envName: "env",
+		version: "20010203040506",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&createIndex{
+					name:       "old",
+					definition: "{}",
+				},
+				&createAlias{
+					name:  "env-x",
+					index: "old",
+				},
+				&writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: "x",
+					definition:         "{}",
+					meta:               "{}",
+					envName:            "env",
+				},
+			)
+		},
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: "{}",
+			Meta: schema.IndexSetMeta{
+				CatchUp: schema.IndexSetMetaCatchUp{Field: "updatedAt", Threshold: 1000},
+			},
+		},
+		expected: []testutil.Matcher{
+			newCreateIndexMatcher(),
+			newReindexMatcher(),
+			newCatchUpMatcher().
+				withFrom("env-x").
+				withTo("env-x_20010203040506").
+				withBlockIndices("old"),
+			newUpdateAliasMatcher(),
+			newWriteChangelogEntryMatcher(),
+		},
+	},
+	&indexSetTestCase{
+		desc:    "update existing index set in place with compatible change",
+		envName: "env",
+		version: "20010203040506",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&createIndex{
+					name:       "old",
+					definition: `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+				},
+				&createAlias{
+					name:  "env-x",
+					index: "old",
+				},
+				&writeChangelogEntry{
+					resourceType:       "index_set",
+					resourceIdentifier: "x",
+					definition:         `{"mappings":{"properties":{"a":{"type":"text"}}}}`,
+					meta:               testutil.MustMarshalJsonAsString(schema.IndexSetMeta{}),
+					envName:            "env",
+				},
+			)
+		},
+		indexSet: IndexSetSpec{
+			Name:    "x",
+			Content: `{"mappings":{"properties":{"a":{"type":"text"},"b":{"type":"keyword"}}},"settings":{"number_of_replicas":0}}`,
+			Meta:    schema.IndexSetMeta{},
+		},
+		expected: []testutil.Matcher{
+			newPutMappingMatcher().
+				withIndex("old").
+				withMapping(`{"properties":{"a":{"type":"text"},"b":{"type":"keyword"}}}`),
+			newPutSettingsMatcher().
+				withIndex("old").
+				withSettings(`{"index.number_of_replicas":0}`),
+			newWriteChangelogEntryMatcher().
+				withFinalName("old"),
+		},
+	},
 	&indexSetTestCase{
 		desc:    "create static index set",
 		envName: "env",
@@ -175,6 +395,10 @@ func (r *indexSetTestCase) Version() string {
 	return r.version
 }
 
+func (r *indexSetTestCase) Prune() bool {
+	return false
+}
+
 func (r *indexSetTestCase) Schema() (schema.Schema, error) {
 	file, err := ioutil.TempFile("", "*")
 
@@ -365,3 +365,419 @@ func (m *indexDocumentMatcher) Match(actual interface{}) testutil.MatchResult {
 
 	return r
 }
+
+func newPutMappingMatcher() *putMappingMatcher {
+	return &putMappingMatcher{}
+}
+
+type putMappingMatcher struct {
+	index   *string
+	mapping *string
+}
+
+func (m *putMappingMatcher) withIndex(index string) *putMappingMatcher {
+	m.index = &index
+	return m
+}
+
+func (m *putMappingMatcher) withMapping(mapping string) *putMappingMatcher {
+	m.mapping = &mapping
+	return m
+}
+
+func (m *putMappingMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*putMapping)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &putMapping{}))
+		return r
+	}
+
+	if m.index != nil {
+		if got, want := a.index, *(m.index); got != want {
+			r.Reject(fmt.Sprintf("got index %q, want %q", got, want))
+		}
+	}
+
+	if m.mapping != nil {
+		if got, want := a.mapping, *(m.mapping); got != want {
+			r.Reject(fmt.Sprintf("got mapping %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newPutSettingsMatcher() *putSettingsMatcher {
+	return &putSettingsMatcher{}
+}
+
+type putSettingsMatcher struct {
+	index    *string
+	settings *string
+}
+
+func (m *putSettingsMatcher) withIndex(index string) *putSettingsMatcher {
+	m.index = &index
+	return m
+}
+
+func (m *putSettingsMatcher) withSettings(settings string) *putSettingsMatcher {
+	m.settings = &settings
+	return m
+}
+
+func (m *putSettingsMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*putSettings)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &putSettings{}))
+		return r
+	}
+
+	if m.index != nil {
+		if got, want := a.index, *(m.index); got != want {
+			r.Reject(fmt.Sprintf("got index %q, want %q", got, want))
+		}
+	}
+
+	if m.settings != nil {
+		if got, want := a.settings, *(m.settings); got != want {
+			r.Reject(fmt.Sprintf("got settings %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newDeleteIndexMatcher() *deleteIndexMatcher {
+	return &deleteIndexMatcher{}
+}
+
+type deleteIndexMatcher struct {
+	name *string
+}
+
+func (m *deleteIndexMatcher) withName(name string) *deleteIndexMatcher {
+	m.name = &name
+	return m
+}
+
+func (m *deleteIndexMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*deleteIndex)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &deleteIndex{}))
+		return r
+	}
+
+	if m.name != nil {
+		if got, want := a.name, *(m.name); got != want {
+			r.Reject(fmt.Sprintf("got name %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newVerifyDocCountMatcher() *verifyDocCountMatcher {
+	return &verifyDocCountMatcher{}
+}
+
+type verifyDocCountMatcher struct {
+	from  *string
+	index *string
+}
+
+func (m *verifyDocCountMatcher) withFrom(from string) *verifyDocCountMatcher {
+	m.from = &from
+	return m
+}
+
+func (m *verifyDocCountMatcher) withIndex(index string) *verifyDocCountMatcher {
+	m.index = &index
+	return m
+}
+
+func (m *verifyDocCountMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*verifyDocCount)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &verifyDocCount{}))
+		return r
+	}
+
+	if m.from != nil {
+		if got, want := a.from, *(m.from); got != want {
+			r.Reject(fmt.Sprintf("got from %q, want %q", got, want))
+		}
+	}
+
+	if m.index != nil {
+		if got, want := a.index, *(m.index); got != want {
+			r.Reject(fmt.Sprintf("got index %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newWaitForHealthMatcher() *waitForHealthMatcher {
+	return &waitForHealthMatcher{}
+}
+
+type waitForHealthMatcher struct {
+	index  *string
+	status *string
+}
+
+func (m *waitForHealthMatcher) withIndex(index string) *waitForHealthMatcher {
+	m.index = &index
+	return m
+}
+
+func (m *waitForHealthMatcher) withStatus(status string) *waitForHealthMatcher {
+	m.status = &status
+	return m
+}
+
+func (m *waitForHealthMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*waitForHealth)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &waitForHealth{}))
+		return r
+	}
+
+	if m.index != nil {
+		if got, want := a.index, *(m.index); got != want {
+			r.Reject(fmt.Sprintf("got index %q, want %q", got, want))
+		}
+	}
+
+	if m.status != nil {
+		if got, want := a.status, *(m.status); got != want {
+			r.Reject(fmt.Sprintf("got status %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newCatchUpMatcher() *catchUpMatcher {
+	return &catchUpMatcher{}
+}
+
+type catchUpMatcher struct {
+	from         *string
+	to           *string
+	blockIndices []string
+}
+
+func (m *catchUpMatcher) withFrom(from string) *catchUpMatcher {
+	m.from = &from
+	return m
+}
+
+func (m *catchUpMatcher) withTo(to string) *catchUpMatcher {
+	m.to = &to
+	return m
+}
+
+func (m *catchUpMatcher) withBlockIndices(indices ...string) *catchUpMatcher {
+	m.blockIndices = indices
+	return m
+}
+
+func (m *catchUpMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*catchUp)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &catchUp{}))
+		return r
+	}
+
+	if m.from != nil {
+		if got, want := a.from, *(m.from); got != want {
+			r.Reject(fmt.Sprintf("got from %q, want %q", got, want))
+		}
+	}
+
+	if m.to != nil {
+		if got, want := a.to, *(m.to); got != want {
+			r.Reject(fmt.Sprintf("got to %q, want %q", got, want))
+		}
+	}
+
+	if m.blockIndices != nil {
+		if got, want := a.blockIndices, m.blockIndices; !reflect.DeepEqual(got, want) {
+			r.Reject(fmt.Sprintf("got block indices %v, want %v", got, want))
+		}
+	}
+
+	return r
+}
+
+func newBlockWritesMatcher() *blockWritesMatcher {
+	return &blockWritesMatcher{}
+}
+
+type blockWritesMatcher struct {
+	index *string
+}
+
+func (m *blockWritesMatcher) withIndex(index string) *blockWritesMatcher {
+	m.index = &index
+	return m
+}
+
+func (m *blockWritesMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*blockWrites)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &blockWrites{}))
+		return r
+	}
+
+	if m.index != nil {
+		if got, want := a.index, *(m.index); got != want {
+			r.Reject(fmt.Sprintf("got index %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newPutPipelineMatcher() *putPipelineMatcher {
+	return &putPipelineMatcher{}
+}
+
+type putPipelineMatcher struct {
+	id         *string
+	definition *string
+}
+
+func (m *putPipelineMatcher) withId(id string) *putPipelineMatcher {
+	m.id = &id
+	return m
+}
+
+func (m *putPipelineMatcher) withDefinition(definition string) *putPipelineMatcher {
+	m.definition = &definition
+	return m
+}
+
+func (m *putPipelineMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*putPipeline)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &putPipeline{}))
+		return r
+	}
+
+	if m.id != nil {
+		if got, want := a.id, *(m.id); got != want {
+			r.Reject(fmt.Sprintf("got id %q, want %q", got, want))
+		}
+	}
+
+	if m.definition != nil {
+		if got, want := a.definition, *(m.definition); got != want {
+			r.Reject(fmt.Sprintf("got definition %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newDeletePipelineMatcher() *deletePipelineMatcher {
+	return &deletePipelineMatcher{}
+}
+
+type deletePipelineMatcher struct {
+	id *string
+}
+
+func (m *deletePipelineMatcher) withId(id string) *deletePipelineMatcher {
+	m.id = &id
+	return m
+}
+
+func (m *deletePipelineMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*deletePipeline)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &deletePipeline{}))
+		return r
+	}
+
+	if m.id != nil {
+		if got, want := a.id, *(m.id); got != want {
+			r.Reject(fmt.Sprintf("got id %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
+
+func newWriteTombstoneMatcher() *writeTombstoneMatcher {
+	return &writeTombstoneMatcher{}
+}
+
+type writeTombstoneMatcher struct {
+	resourceType       *string
+	resourceIdentifier *string
+}
+
+func (m *writeTombstoneMatcher) withResourceType(resourceType string) *writeTombstoneMatcher {
+	m.resourceType = &resourceType
+	return m
+}
+
+func (m *writeTombstoneMatcher) withResourceIdentifier(resourceIdentifier string) *writeTombstoneMatcher {
+	m.resourceIdentifier = &resourceIdentifier
+	return m
+}
+
+func (m *writeTombstoneMatcher) Match(actual interface{}) testutil.MatchResult {
+	r := testutil.NewMatchResult()
+
+	a, ok := actual.(*writeTombstone)
+
+	if !ok {
+		r.Reject(fmt.Sprintf("got %T, want %T", actual, &writeTombstone{}))
+		return r
+	}
+
+	if m.resourceType != nil {
+		if got, want := a.resourceType, *(m.resourceType); got != want {
+			r.Reject(fmt.Sprintf("got resourceType %q, want %q", got, want))
+		}
+	}
+
+	if m.resourceIdentifier != nil {
+		if got, want := a.resourceIdentifier, *(m.resourceIdentifier); got != want {
+			r.Reject(fmt.Sprintf("got resourceIdentifier %q, want %q", got, want))
+		}
+	}
+
+	return r
+}
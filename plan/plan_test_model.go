@@ -11,6 +11,8 @@ type PlanTestCase interface {
 	Desc() string
 	EnvName() string
 	Version() string
+	// Prune is whether the plan removes resources no longer in the schema
+	Prune() bool
 	Schema() (schema.Schema, error)
 	Setup() func(setup Setup)
 	Expected() []testutil.Matcher
@@ -0,0 +1,173 @@
+package plan
+
+import (
+	"github.com/hdpe.me/esup/schema"
+	"github.com/hdpe.me/esup/testutil"
+	"github.com/hdpe.me/esup/util"
+	"os"
+)
+
+const testPipelineDef = `{"description":"d","processors":[{"set":{"field":"f","value":"v"}}]}`
+
+var pipelineTestCases = []PlanTestCase{
+	&pipelineTestCase{
+		desc:      "create fresh pipeline",
+		envName:   "env",
+		pipelines: []PipelineSpec{{Name: "p", Content: testPipelineDef}},
+		expected: []testutil.Matcher{
+			newPutPipelineMatcher().
+				withId("env-p").
+				withDefinition(testPipelineDef),
+			newWriteChangelogEntryMatcher().
+				withResourceType("pipeline").
+				withResourceIdentifier("p").
+				withFinalName("env-p").
+				withDefinition(testPipelineDef).
+				withEnvName("env"),
+		},
+	},
+	&pipelineTestCase{
+		desc:    "record existing pipeline missing from changelog without putting it",
+		envName: "env",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&putPipeline{
+					id:         "env-p",
+					definition: testPipelineDef,
+				},
+			)
+		},
+		pipelines: []PipelineSpec{{Name: "p", Content: testPipelineDef}},
+		expected: []testutil.Matcher{
+			newWriteChangelogEntryMatcher().
+				withResourceType("pipeline").
+				withResourceIdentifier("p").
+				withFinalName("env-p"),
+		},
+	},
+	&pipelineTestCase{
+		desc:    "leave recorded pipeline unchanged",
+		envName: "env",
+		setup: func(setup Setup) {
+			setup.Apply(
+				&putPipeline{
+					id:         "env-p",
+					definition: testPipelineDef,
+				},
+				&writeChangelogEntry{
+					resourceType:       "pipeline",
+					resourceIdentifier: "p",
+					finalName:          "env-p",
+					definition:         testPipelineDef,
+					meta:               "{}",
+					envName:            "env",
+				},
+			)
+		},
+		pipelines: []PipelineSpec{{Name: "p", Content: testPipelineDef}},
+		expected:  []testutil.Matcher{},
+	},
+	&pipelineTestCase{
+		desc:    "prune only pipelines recorded in changelog",
+		envName: "env",
+		prune:   true,
+		setup: func(setup Setup) {
+			setup.Apply(
+				&putPipeline{
+					id:         "env-p",
+					definition: testPipelineDef,
+				},
+				&writeChangelogEntry{
+					resourceType:       "pipeline",
+					resourceIdentifier: "p",
+					finalName:          "env-p",
+					definition:         testPipelineDef,
+					meta:               "{}",
+					envName:            "env",
+				},
+				&putPipeline{
+					id:         "env-unmanaged",
+					definition: testPipelineDef,
+				},
+			)
+		},
+		expected: []testutil.Matcher{
+			newDeletePipelineMatcher().
+				withId("env-p"),
+			newWriteTombstoneMatcher().
+				withResourceType("pipeline").
+				withResourceIdentifier("p"),
+		},
+	},
+}
+
+type pipelineTestCase struct {
+	desc      string
+	envName   string
+	pipelines []PipelineSpec
+	prune     bool
+	setup     func(Setup)
+	expected  []testutil.Matcher
+
+	// temp files containing pipeline definitions
+	filePaths []string
+}
+
+func (r *pipelineTestCase) Desc() string {
+	return r.desc
+}
+
+func (r *pipelineTestCase) EnvName() string {
+	return r.envName
+}
+
+func (r *pipelineTestCase) Version() string {
+	return ""
+}
+
+func (r *pipelineTestCase) Prune() bool {
+	return r.prune
+}
+
+func (r *pipelineTestCase) Schema() (schema.Schema, error) {
+	pipelines := make([]schema.Pipeline, 0, len(r.pipelines))
+
+	for _, p := range r.pipelines {
+		filePath, err := writeTempFile(p.Content)
+
+		if err != nil {
+			return schema.Schema{}, err
+		}
+
+		r.filePaths = append(r.filePaths, filePath)
+		pipelines = append(pipelines, schema.Pipeline{Name: p.Name, FilePath: filePath})
+	}
+
+	return schema.Schema{
+		EnvName:   r.envName,
+		Pipelines: pipelines,
+	}, nil
+}
+
+func (r *pipelineTestCase) Setup() func(setup Setup) {
+	return r.setup
+}
+
+func (r *pipelineTestCase) Expected() []testutil.Matcher {
+	return r.expected
+}
+
+func (r *pipelineTestCase) Clean() error {
+	errs := make([]error, 0, len(r.filePaths))
+
+	for _, filePath := range r.filePaths {
+		errs = append(errs, os.Remove(filePath))
+	}
+
+	return util.AnyErrors(errs...)
+}
+
+type PipelineSpec struct {
+	Name    string
+	Content string
+}
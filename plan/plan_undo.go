@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/hdpe.me/esup/es"
+)
+
+// UndoStep describes one change undone after a failed migration, and whether undoing it failed
+type UndoStep struct {
+	Description string
+	Err         error
+}
+
+func (s UndoStep) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%v: failed: %v", s.Description, s.Err)
+	}
+	return s.Description
+}
+
+// Undo reverts the changes recorded by a collector during a failed migration: it cancels any reindex task still
+// running, lifts the write blocks put on indices, deletes the indices created, except any an alias has already been
+// pointed at, and restores the pipelines put to their previous definitions. Aliases are left as they are. Indices
+// and reindex tasks whose requests were interrupted are looked for, and undone if they were created.
+func Undo(es *es.Client, collector *Collector) []UndoStep {
+	steps := make([]UndoStep, 0)
+	cancelled := make(map[string]bool)
+
+	for _, index := range collector.Indices {
+		taskId, ok := collector.ReindexTasks[index]
+
+		if !ok {
+			continue
+		}
+
+		if status, err := es.GetTaskStatus(taskId); err == nil && status.IsCompleted {
+			continue
+		}
+
+		cancelled[taskId] = true
+		steps = append(steps, UndoStep{
+			Description: fmt.Sprintf("cancel reindex task %v", taskId),
+			Err:         es.CancelTask(taskId),
+		})
+	}
+
+	for _, index := range collector.PendingReindexes {
+		tasks, err := es.GetReindexTasks(index)
+
+		if err != nil {
+			steps = append(steps, UndoStep{Description: fmt.Sprintf("cancel reindex tasks into %v", index),
+				Err: err})
+			continue
+		}
+
+		for _, task := range tasks {
+			if cancelled[task.Id] {
+				continue
+			}
+
+			cancelled[task.Id] = true
+			steps = append(steps, UndoStep{
+				Description: fmt.Sprintf("cancel reindex task %v", task.Id),
+				Err:         es.CancelTask(task.Id),
+			})
+		}
+	}
+
+	for i := len(collector.BlockedIndices) - 1; i >= 0; i-- {
+		index := collector.BlockedIndices[i]
+
+		steps = append(steps, UndoStep{
+			Description: fmt.Sprintf("lift write block on index %v", index),
+			Err:         es.PutSettings(index, `{"index.blocks.write":null}`),
+		})
+	}
+
+	indices := append([]string{}, collector.Indices...)
+
+	for _, index := range collector.PendingIndices {
+		if containsString(indices, index) {
+			continue
+		}
+
+		existing, err := es.GetIndices(index)
+
+		if err != nil {
+			steps = append(steps, UndoStep{Description: fmt.Sprintf("delete index %v", index), Err: err})
+			continue
+		}
+
+		if len(existing) > 0 {
+			indices = append(indices, index)
+		}
+	}
+
+	for i := len(indices) - 1; i >= 0; i-- {
+		index := indices[i]
+		aliases, err := es.GetAliases(index)
+
+		if err != nil {
+			steps = append(steps, UndoStep{Description: fmt.Sprintf("delete index %v", index), Err: err})
+			continue
+		}
+
+		if len(aliases[index]) > 0 {
+			steps = append(steps, UndoStep{Description: fmt.Sprintf("keep index %v, as alias %v refers to it",
+				index, aliases[index][0])})
+			continue
+		}
+
+		steps = append(steps, UndoStep{
+			Description: fmt.Sprintf("delete index %v", index),
+			Err:         es.DeleteIndex(index),
+		})
+	}
+
+	restored := make(map[string]bool)
+
+	for i := len(collector.Pipelines) - 1; i >= 0; i-- {
+		id := collector.Pipelines[i]
+
+		if restored[id] {
+			continue
+		}
+
+		restored[id] = true
+		previous := collector.PreviousPipelines[id]
+
+		if previous == "" {
+			steps = append(steps, UndoStep{
+				Description: fmt.Sprintf("delete pipeline %v", id),
+				Err:         es.DeletePipeline(id),
+			})
+		} else {
+			steps = append(steps, UndoStep{
+				Description: fmt.Sprintf("restore previous definition of pipeline %v", id),
+				Err:         es.PutPipelineDef(id, previous),
+			})
+		}
+	}
+
+	return steps
+}
@@ -0,0 +1,91 @@
+package plan
+
+import (
+	"github.com/hdpe.me/esup/schema"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	c, err := NewElasticsearchContainer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := c.Terminate(); err != nil {
+			println(err)
+		}
+	}()
+
+	ctx, err := GetContext(c, schema.Schema{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupColl := NewCollector()
+	defer CleanUp(ctx, setupColl)
+
+	setup := Setup{
+		es:        ctx.Es,
+		changelog: ctx.Changelog,
+		collector: setupColl,
+		onError: func(err error) {
+			t.Fatalf("error in test setup: %v", err)
+		},
+	}
+
+	setup.Apply(&putPipeline{id: "env-p", definition: `{"processors":[]}`})
+
+	coll := NewCollector()
+	defer CleanUp(ctx, coll)
+
+	for _, item := range []PlanAction{
+		&putPipeline{id: "env-p", definition: `{"description":"new","processors":[]}`},
+		&putPipeline{id: "env-q", definition: `{"processors":[]}`},
+		&createIndex{name: "env-x_1", definition: "{}"},
+		&createIndex{name: "env-y_1", definition: "{}"},
+		&createAlias{name: "env-y", index: "env-y_1"},
+	} {
+		if err = item.Execute(ctx.Es, ctx.Changelog, coll); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = ctx.Es.PutSettings("env-y_1", `{"index.blocks.write":true}`); err != nil {
+		t.Fatal(err)
+	}
+
+	coll.addBlockedIndex("env-y_1")
+
+	for _, step := range Undo(ctx.Es, coll) {
+		if step.Err != nil {
+			t.Errorf("%v", step)
+		}
+	}
+
+	if indices, _ := ctx.Es.GetIndices("env-x_1"); len(indices) != 0 {
+		t.Errorf("wanted env-x_1 deleted")
+	}
+
+	if indices, _ := ctx.Es.GetIndices("env-y_1"); len(indices) != 1 {
+		t.Errorf("wanted aliased env-y_1 kept")
+	}
+
+	if blocked, _ := ctx.Es.GetIndexSetting("env-y_1", "index.blocks.write"); blocked != "" {
+		t.Errorf("got env-y_1 write block %v, want lifted", blocked)
+	}
+
+	if def, _ := ctx.Es.GetPipelineDef("env-p"); def != `{"processors":[]}` {
+		t.Errorf("got env-p %v, want previous definition", def)
+	}
+
+	if def, _ := ctx.Es.GetPipelineDef("env-q"); def != "" {
+		t.Errorf("wanted env-q deleted")
+	}
+}
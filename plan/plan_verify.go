@@ -0,0 +1,13 @@
+package plan
+
+// withinTolerance returns whether a count differs from the count wanted by no more than the tolerance, as a
+// percentage of the count wanted
+func withinTolerance(got int64, want int64, tolerance float64) bool {
+	diff := got - want
+
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return float64(diff) <= float64(want)*tolerance/100
+}
@@ -0,0 +1,27 @@
+package plan
+
+import (
+	"testing"
+)
+
+func Test_withinTolerance(t *testing.T) {
+	testCases := []struct {
+		got       int64
+		want      int64
+		tolerance float64
+		expected  bool
+	}{
+		{got: 100, want: 100, expected: true},
+		{got: 99, want: 100, expected: false},
+		{got: 99, want: 100, tolerance: 1, expected: true},
+		{got: 102, want: 100, tolerance: 1, expected: false},
+		{got: 0, want: 0, expected: true},
+	}
+
+	for _, tc := range testCases {
+		if got := withinTolerance(tc.got, tc.want, tc.tolerance); got != tc.expected {
+			t.Errorf("withinTolerance(%v, %v, %v): got %v, want %v", tc.got, tc.want, tc.tolerance, got,
+				tc.expected)
+		}
+	}
+}
@@ -26,6 +26,34 @@ func (r *Changelog) GetCurrentChangelogEntry(resourceType string, resourceIdenti
 	return es.GetChangelogEntry(r.es, r.config.Index, resourceType, resourceIdentifier, envName)
 }
 
+// GetChangelogEntries returns every changelog entry for a resource, oldest first
+func (r *Changelog) GetChangelogEntries(resourceType string, resourceIdentifier string, envName string) ([]es.ChangelogEntry, error) {
+	if err := r.createIndexIfRequired(); err != nil {
+		return nil, err
+	}
+
+	return es.GetChangelogEntries(r.es, r.config.Index, resourceType, resourceIdentifier, envName)
+}
+
+// GetAllChangelogEntries returns every changelog entry for every environment, oldest first
+func (r *Changelog) GetAllChangelogEntries() ([]es.ChangelogEntry, error) {
+	if err := r.createIndexIfRequired(); err != nil {
+		return nil, err
+	}
+
+	return es.GetAllChangelogEntries(r.es, r.config.Index)
+}
+
+// GetLatestChangelogEntries returns the latest changelog entry, which may be a tombstone, for each resource in an
+// environment
+func (r *Changelog) GetLatestChangelogEntries(envName string) ([]es.ChangelogEntry, error) {
+	if err := r.createIndexIfRequired(); err != nil {
+		return nil, err
+	}
+
+	return es.GetLatestChangelogEntries(r.es, r.config.Index, envName)
+}
+
 func (r *Changelog) PutChangelogEntry(resourceType string, resourceIdentifier string, finalName string,
 	entry es.ChangelogEntry, envName string) error {
 
@@ -0,0 +1,58 @@
+package resource
+
+import (
+	"github.com/hdpe.me/esup/config"
+	"github.com/hdpe.me/esup/es"
+)
+
+func NewJournal(conf config.ChangelogConfig, es *es.Client) *Journal {
+	return &Journal{
+		config: conf,
+		es:     es,
+	}
+}
+
+// Journal records the progress of plan execution in each environment, so an interrupted run can be resumed
+type Journal struct {
+	config      config.ChangelogConfig
+	es          *es.Client
+	indexExists bool
+}
+
+func (r *Journal) Get(envName string) (es.JournalEntry, error) {
+	if err := r.createIndexIfRequired(); err != nil {
+		return es.JournalEntry{}, err
+	}
+
+	return es.GetJournalEntry(r.es, r.config.JournalIndex, envName)
+}
+
+func (r *Journal) Put(envName string, entry es.JournalEntry) error {
+	if err := r.createIndexIfRequired(); err != nil {
+		return err
+	}
+
+	return es.PutJournalEntry(r.es, r.config.JournalIndex, envName, entry)
+}
+
+func (r *Journal) createIndexIfRequired() error {
+	if r.indexExists {
+		return nil
+	}
+
+	def, err := r.es.GetIndexDef(r.config.JournalIndex)
+
+	if err != nil {
+		return err
+	}
+
+	if def == "" {
+		if err = es.CreateJournalIndex(r.es, r.config.JournalIndex); err != nil {
+			return err
+		}
+	}
+
+	r.indexExists = true
+
+	return nil
+}
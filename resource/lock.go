@@ -1,8 +1,14 @@
 package resource
 
 import (
+	"errors"
+	"fmt"
 	"github.com/hdpe.me/esup/config"
 	"github.com/hdpe.me/esup/es"
+	"os"
+	"os/user"
+	"sync"
+	"time"
 )
 
 func NewLock(conf config.ChangelogConfig, es *es.Client) *Lock {
@@ -12,37 +18,324 @@ func NewLock(conf config.ChangelogConfig, es *es.Client) *Lock {
 	}
 }
 
+// Lock is a lease on the lock for an environment, or in global mode for every environment at once, renewed by a
+// heartbeat while it's held so the lock can be taken over if the process holding it dies
 type Lock struct {
 	config config.ChangelogConfig
 	es     *es.Client
+	global bool
+
+	mu     sync.Mutex
+	lockId string
+	holder es.LockEntry
+	stop   chan struct{}
+	// lost is closed when the lock is found to have been taken over while held
+	lost chan struct{}
 }
 
 var lockClientId = "esup"
 
+// LockedError is returned when another process holds the lock
+type LockedError struct {
+	Entry es.LockEntry
+}
+
+func (e *LockedError) Error() string {
+	msg := "changelog is locked"
+
+	if e.Entry.IsGlobal() {
+		msg = "changelog is globally locked"
+	}
+
+	msg += fmt.Sprintf(" by %v", e.Entry.Holder())
+
+	if e.Entry.EnvName != "" {
+		msg += fmt.Sprintf(" migrating %v", e.Entry.EnvName)
+	}
+
+	msg += fmt.Sprintf(" since %v", e.Entry.Timestamp)
+
+	if e.Entry.Expires != "" {
+		msg += fmt.Sprintf(" - lease expires %v", e.Entry.Expires)
+	}
+
+	return msg
+}
+
+// SetGlobal sets whether Get locks every environment, for operations which span environments
+func (r *Lock) SetGlobal(global bool) {
+	r.global = global
+}
+
 func (r *Lock) Get(envName string) error {
-	index := r.config.LockIndex
+	if err := r.createIndexIfRequired(); err != nil {
+		return err
+	}
 
-	def, err := r.es.GetIndexDef(index)
+	if !r.global && envName == es.GlobalLockId {
+		return fmt.Errorf("can't lock environment %v: the name is reserved for the global lock", envName)
+	}
+
+	lockId := envName
+
+	if r.global {
+		lockId = es.GlobalLockId
+	} else if err := r.checkUnlocked(es.GlobalLockId); err != nil {
+		return err
+	}
+
+	if err := r.acquire(lockId, envName); err != nil {
+		return err
+	}
+
+	// check again for a conflicting lock taken while acquiring this one
+	var err error
+
+	if r.global {
+		err = r.checkNoEnvironmentLocked()
+	} else {
+		err = r.checkUnlocked(es.GlobalLockId)
+	}
 
 	if err != nil {
+		if releaseErr := r.Release(envName); releaseErr != nil {
+			println(fmt.Sprintf("couldn't release lock: %v", releaseErr))
+		}
 		return err
 	}
 
+	return nil
+}
+
+func (r *Lock) Release(envName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+
+	entry, err := es.GetLock(r.es, r.config.LockIndex, r.lockId)
+
+	if err != nil {
+		return err
+	}
+
+	if !entry.IsLocked() {
+		return nil
+	}
+
+	if !isSameHolder(entry, r.holder) {
+		return &LockedError{entry}
+	}
+
+	return es.PutUnlocked(r.es, entry.Version, r.config.LockIndex, r.lockId, lockClientId, envName)
+}
+
+// Status returns the state of every lock which has been taken
+func (r *Lock) Status() ([]es.LockEntry, error) {
+	def, err := r.es.GetIndexDef(r.config.LockIndex)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if def == "" {
+		return []es.LockEntry{}, nil
+	}
+
+	return es.GetLocks(r.es, r.config.LockIndex)
+}
+
+// Entry returns the state of the lock with the given ID
+func (r *Lock) Entry(lockId string) (es.LockEntry, error) {
+	def, err := r.es.GetIndexDef(r.config.LockIndex)
+
+	if err != nil {
+		return es.LockEntry{}, err
+	}
+
 	if def == "" {
-		if err = es.CreateLockIndex(r.es, index); err != nil {
-			return err
+		return es.LockEntry{LockId: lockId, Status: es.LockStatusUnlocked}, nil
+	}
+
+	return es.GetLock(r.es, r.config.LockIndex, lockId)
+}
+
+// ForceRelease releases the lock with the given ID whoever holds it, returning the entry released
+func (r *Lock) ForceRelease(lockId string) (es.LockEntry, error) {
+	entry, err := r.Entry(lockId)
+
+	if err != nil || !entry.IsLocked() {
+		return entry, err
+	}
+
+	return entry, es.PutUnlocked(r.es, entry.Version, r.config.LockIndex, lockId, lockClientId, entry.EnvName)
+}
+
+func (r *Lock) acquire(lockId string, envName string) error {
+	entry, err := es.GetLock(r.es, r.config.LockIndex, lockId)
+
+	if err != nil {
+		return err
+	}
+
+	if entry.IsLocked() {
+		if !entry.IsExpired(time.Now()) {
+			return &LockedError{entry}
 		}
+
+		println(fmt.Sprintf("Taking over lock held by %v since %v, whose lease expired %v", entry.Holder(),
+			entry.Timestamp, entry.Expires))
+	}
+
+	now := time.Now()
+	holder := newLockHolder(envName)
+	holder.Timestamp = es.FormatTimestamp(now)
+	holder.Expires = es.FormatTimestamp(now.Add(r.config.LockLease))
+
+	if err = es.PutLocked(r.es, entry.Version, r.config.LockIndex, lockId, holder); err != nil {
+		return err
 	}
 
-	version, err := es.GetLock(r.es, index)
+	r.mu.Lock()
+	r.lockId = lockId
+	r.holder = holder
+	r.stop = make(chan struct{})
+	r.lost = make(chan struct{})
+	r.mu.Unlock()
+
+	go r.heartbeat(r.stop, r.lost)
+
+	return nil
+}
+
+// checkUnlocked returns an error if another process holds the lock with the given ID
+func (r *Lock) checkUnlocked(lockId string) error {
+	entry, err := es.GetLock(r.es, r.config.LockIndex, lockId)
 
 	if err != nil {
 		return err
 	}
 
-	return es.PutLocked(r.es, version, index, lockClientId, envName)
+	if entry.IsLocked() && !entry.IsExpired(time.Now()) {
+		return &LockedError{entry}
+	}
+
+	return nil
 }
 
-func (r *Lock) Release(envName string) error {
-	return es.PutUnlocked(r.es, r.config.LockIndex, lockClientId, envName)
+// checkNoEnvironmentLocked returns an error if another process holds the lock for any environment
+func (r *Lock) checkNoEnvironmentLocked() error {
+	entries, err := es.GetLocks(r.es, r.config.LockIndex)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsGlobal() && entry.IsLocked() && !entry.IsExpired(time.Now()) {
+			return &LockedError{entry}
+		}
+	}
+
+	return nil
+}
+
+// Lost returns a channel which is closed if the lock is found to have been taken over while held, so whatever it
+// guards can be stopped
+func (r *Lock) Lost() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lost
+}
+
+// heartbeat renews the lease on the lock until stopped, or until the lock is found to have been taken over, when
+// lost is closed
+func (r *Lock) heartbeat(stop chan struct{}, lost chan struct{}) {
+	ticker := time.NewTicker(r.config.LockLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.renew(); err != nil {
+				println(fmt.Sprintf("couldn't renew lock: %v", err))
+
+				var lockedErr *LockedError
+				if errors.As(err, &lockedErr) {
+					close(lost)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Lock) renew() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the lock was released while waiting to renew it
+	if r.stop == nil {
+		return nil
+	}
+
+	entry, err := es.GetLock(r.es, r.config.LockIndex, r.lockId)
+
+	if err != nil {
+		return err
+	}
+
+	if !entry.IsLocked() || !isSameHolder(entry, r.holder) {
+		return &LockedError{entry}
+	}
+
+	r.holder.Expires = es.FormatTimestamp(time.Now().Add(r.config.LockLease))
+
+	return es.PutLocked(r.es, entry.Version, r.config.LockIndex, r.lockId, r.holder)
+}
+
+func (r *Lock) createIndexIfRequired() error {
+	def, err := r.es.GetIndexDef(r.config.LockIndex)
+
+	if err != nil {
+		return err
+	}
+
+	if def == "" {
+		return es.CreateLockIndex(r.es, r.config.LockIndex)
+	}
+
+	return nil
+}
+
+func newLockHolder(envName string) es.LockEntry {
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	username := os.Getenv("USER")
+
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	return es.LockEntry{
+		ClientId: lockClientId,
+		EnvName:  envName,
+		Hostname: hostname,
+		Pid:      os.Getpid(),
+		User:     username,
+	}
+}
+
+func isSameHolder(entry es.LockEntry, holder es.LockEntry) bool {
+	return entry.Hostname == holder.Hostname && entry.Pid == holder.Pid && entry.Timestamp == holder.Timestamp
 }
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"time"
 )
 
 type Schema struct {
@@ -38,6 +39,14 @@ type IndexSet struct {
 	IndexSet string
 	FilePath string
 	Meta     IndexSetMeta
+	// Checks are the smoke checks a new index must pass before the alias is pointed at it
+	Checks []IndexSetCheck
+}
+
+// IndexSetCheck is a file holding a search of an index set and what's expected of its results
+type IndexSetCheck struct {
+	Name     string
+	FilePath string
 }
 
 func (is IndexSet) ResourceIdentifier() string {
@@ -55,11 +64,16 @@ func (d Document) ResourceIdentifier() string {
 	return fmt.Sprintf("%v/%v", d.IndexSet, d.Name)
 }
 
-// these fields in these structs must remain exported because we marshal them as JSON for the diff
+// these fields in these structs must remain exported because we marshal them as JSON for the diff. Fields tagged
+// `json:"-"` are left out of the changelog as changing them doesn't require reindexing.
 type IndexSetMeta struct {
 	Index     string
 	Prototype IndexSetMetaPrototype
 	Reindex   IndexSetMetaReindex
+	Retention IndexSetMetaRetention `json:"-"`
+	Verify    IndexSetMetaVerify    `json:"-"`
+	Health    IndexSetMetaHealth    `json:"-"`
+	CatchUp   IndexSetMetaCatchUp   `json:"-"`
 }
 
 type IndexSetMetaPrototype struct {
@@ -69,8 +83,55 @@ type IndexSetMetaPrototype struct {
 
 type IndexSetMetaReindex struct {
 	Pipeline string
+	// RequestsPerSecond throttles reindexing, or is 0 to leave it unthrottled
+	RequestsPerSecond int `json:"-"`
+	// Slices is the number of slices to divide reindexing into, "auto", or "" to not slice it
+	Slices string `json:"-"`
+	// BatchSize is the number of documents copied in each batch, or 0 for the Elasticsearch default
+	BatchSize int `json:"-"`
+	// BlockWrites is whether to block writes to the old index while reindexing from it
+	BlockWrites bool `json:"-"`
+}
+
+type IndexSetMetaRetention struct {
+	Keep      int
+	Action    string
+	AfterDays int
+}
+
+// IndexSetMetaVerify configures the checks made on a new index before the alias is pointed at it
+type IndexSetMetaVerify struct {
+	// DocCount is whether to check the new index has as many documents as the index it was reindexed from
+	DocCount bool
+	// Tolerance is the percentage of documents by which the counts may differ
+	Tolerance float64
+}
+
+// IndexSetMetaHealth configures waiting for a new index to become healthy before the alias is pointed at it
+type IndexSetMetaHealth struct {
+	// Status is "green" or "yellow", or "" to not wait
+	Status string
+	// Timeout is how long to wait for the status
+	Timeout time.Duration
 }
 
+// IndexSetMetaCatchUp configures copying documents written to the old index while reindexing to the new index
+type IndexSetMetaCatchUp struct {
+	// Field is the date field set when a document is written, or "" to not catch up
+	Field string
+	// Threshold is the most documents a catch-up pass may copy for writes to the old index to be blocked for the last
+	Threshold int
+	// Margin is how far before each pass's start the next pass copies from, allowing for clock skew and writes in
+	// flight
+	Margin time.Duration
+}
+
+const (
+	RetentionActionClose    = "close"
+	RetentionActionReadOnly = "readOnly"
+	RetentionActionDelete   = "delete"
+)
+
 type DocumentMeta struct {
 	Ignored bool
 }
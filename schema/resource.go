@@ -9,6 +9,9 @@ import (
 	"syscall"
 )
 
+// checksExt is the extension of the directories holding an index set's checks
+const checksExt = "checks"
+
 type resource struct {
 	identifier string
 	envName    string
@@ -33,7 +36,12 @@ func getAllResources(directory string, ext string) ([]resource, error) {
 			return err
 		}
 
-		if info.IsDir() || !hasExtension(info.Name(), ext) {
+		// checks directories are resources in their own right, and their contents aren't resources at all
+		if info.IsDir() && hasExtension(info.Name(), checksExt) && ext != checksExt {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() != (ext == checksExt) || !hasExtension(info.Name(), ext) {
 			return nil
 		}
 
@@ -46,6 +54,10 @@ func getAllResources(directory string, ext string) ([]resource, error) {
 
 		resources = append(resources, resource{identifier, env, path})
 
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+
 		return nil
 	})
 
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"github.com/hdpe.me/esup/config"
 	viperlib "github.com/spf13/viper"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func GetSchema(config config.Config, envName string) (Schema, error) {
@@ -50,11 +54,57 @@ func getIndexSets(config config.IndexSetsConfig, envName string) ([]IndexSet, er
 		return nil, err
 	}
 
+	defaultMeta := DefaultIndexSetMeta()
+	defaultMeta.Retention = IndexSetMetaRetention{
+		Keep:      config.Retention.Keep,
+		Action:    config.Retention.Action,
+		AfterDays: config.Retention.AfterDays,
+	}
+
+	if err = validateRetention(defaultMeta.Retention); err != nil {
+		return nil, fmt.Errorf("invalid indexSets.retention configuration: %w", err)
+	}
+
+	defaultMeta.Reindex = IndexSetMetaReindex{
+		RequestsPerSecond: config.Reindex.RequestsPerSecond,
+		Slices:            config.Reindex.Slices,
+		BatchSize:         config.Reindex.BatchSize,
+	}
+
+	if err = validateReindex(defaultMeta.Reindex); err != nil {
+		return nil, fmt.Errorf("invalid indexSets.reindex configuration: %w", err)
+	}
+
+	defaultMeta.Health = IndexSetMetaHealth{
+		Status:  config.Health.Status,
+		Timeout: config.Health.Timeout,
+	}
+
+	if err = validateHealth(defaultMeta.Health); err != nil {
+		return nil, fmt.Errorf("invalid indexSets.health configuration: %w", err)
+	}
+
+	checksRes, err := getEnvironmentResources(config.Directory, envName, checksExt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	checksByIdentifier := make(map[string][]IndexSetCheck)
+
+	for _, r := range checksRes {
+		checksByIdentifier[r.identifier], err = getIndexSetChecks(r.filePath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	indexSetsByIdentifier := make(map[string]IndexSet)
 	indexSetMetaByIdentifier := make(map[string]IndexSetMeta)
 
 	for _, r := range metaRes {
-		indexSetMetaByIdentifier[r.identifier], err = readIndexSetMeta(r.filePath)
+		indexSetMetaByIdentifier[r.identifier], err = readIndexSetMeta(r.filePath, defaultMeta)
 
 		if err != nil {
 			return nil, err
@@ -66,13 +116,14 @@ func getIndexSets(config config.IndexSetsConfig, envName string) ([]IndexSet, er
 		meta, ok := indexSetMetaByIdentifier[r.identifier]
 
 		if !ok {
-			meta = DefaultIndexSetMeta()
+			meta = defaultMeta
 		}
 
 		indexSet := IndexSet{
 			IndexSet: r.identifier,
 			FilePath: r.filePath,
 			Meta:     meta,
+			Checks:   checksByIdentifier[r.identifier],
 		}
 		indexSetsByIdentifier[r.identifier] = indexSet
 		indexSets = append(indexSets, indexSet)
@@ -83,6 +134,7 @@ func getIndexSets(config config.IndexSetsConfig, envName string) ([]IndexSet, er
 			indexSets = append(indexSets, IndexSet{
 				IndexSet: id,
 				Meta:     m,
+				Checks:   checksByIdentifier[id],
 			})
 		}
 	}
@@ -97,8 +149,38 @@ func getIndexSets(config config.IndexSetsConfig, envName string) ([]IndexSet, er
 	return indexSets, nil
 }
 
-func readIndexSetMeta(filePath string) (IndexSetMeta, error) {
-	meta := DefaultIndexSetMeta()
+// getIndexSetChecks returns the checks in a checks directory, ordered by name
+func getIndexSetChecks(directory string) ([]IndexSetCheck, error) {
+	files, err := ioutil.ReadDir(directory)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read checks from %v: %w", directory, err)
+	}
+
+	checks := make([]IndexSetCheck, 0)
+
+	for _, f := range files {
+		if f.IsDir() || !hasExtension(f.Name(), "json") {
+			continue
+		}
+
+		checks = append(checks, IndexSetCheck{
+			Name:     f.Name()[0 : len(f.Name())-len(".json")],
+			FilePath: filepath.Join(directory, f.Name()),
+		})
+	}
+
+	return checks, nil
+}
+
+// defaultCatchUpThreshold is the most documents a catch-up pass copies before writes are blocked, unless configured
+const defaultCatchUpThreshold = 1000
+
+// defaultCatchUpMargin is how far before each pass's start the next pass copies from, unless configured
+const defaultCatchUpMargin = time.Minute
+
+func readIndexSetMeta(filePath string, defaultMeta IndexSetMeta) (IndexSetMeta, error) {
+	meta := defaultMeta
 
 	viper := viperlib.New()
 	viper.Set("Verbose", true)
@@ -143,11 +225,170 @@ func readIndexSetMeta(filePath string) (IndexSetMeta, error) {
 
 	if reindexConfig != nil {
 		meta.Reindex.Pipeline = reindexConfig.GetString("pipeline")
+
+		if reindexConfig.IsSet("requestsPerSecond") {
+			meta.Reindex.RequestsPerSecond = reindexConfig.GetInt("requestsPerSecond")
+		}
+		if reindexConfig.IsSet("slices") {
+			meta.Reindex.Slices = reindexConfig.GetString("slices")
+		}
+		if reindexConfig.IsSet("batchSize") {
+			meta.Reindex.BatchSize = reindexConfig.GetInt("batchSize")
+		}
+		if reindexConfig.IsSet("blockWrites") {
+			meta.Reindex.BlockWrites = reindexConfig.GetBool("blockWrites")
+		}
+
+		if err = validateReindex(meta.Reindex); err != nil {
+			return meta, fmt.Errorf("invalid reindexing configuration: %w", err)
+		}
+	}
+
+	retentionConfig := viper.Sub("retention")
+
+	if meta.Index != "" && retentionConfig != nil {
+		return meta, fmt.Errorf("can't specify both static index and retention configuration")
+	}
+
+	if retentionConfig != nil {
+		if retentionConfig.IsSet("keep") {
+			meta.Retention.Keep = retentionConfig.GetInt("keep")
+		}
+		if retentionConfig.IsSet("action") {
+			meta.Retention.Action = retentionConfig.GetString("action")
+		}
+		if retentionConfig.IsSet("afterDays") {
+			meta.Retention.AfterDays = retentionConfig.GetInt("afterDays")
+		}
+
+		if err = validateRetention(meta.Retention); err != nil {
+			return meta, fmt.Errorf("invalid retention configuration: %w", err)
+		}
+	}
+
+	verifyConfig := viper.Sub("verify")
+
+	if meta.Index != "" && verifyConfig != nil {
+		return meta, fmt.Errorf("can't specify both static index and verification configuration")
+	}
+
+	if verifyConfig != nil {
+		meta.Verify.DocCount = verifyConfig.GetBool("docCount")
+		meta.Verify.Tolerance = verifyConfig.GetFloat64("tolerance")
+
+		if meta.Verify.Tolerance < 0 {
+			return meta, fmt.Errorf("invalid verification configuration: tolerance can't be negative")
+		}
+	}
+
+	catchUpConfig := viper.Sub("catchUp")
+
+	if meta.Index != "" && catchUpConfig != nil {
+		return meta, fmt.Errorf("can't specify both static index and catch-up configuration")
+	}
+
+	if meta.Reindex.BlockWrites && catchUpConfig != nil {
+		return meta, fmt.Errorf("can't specify both blocking writes while reindexing and catch-up configuration")
+	}
+
+	if catchUpConfig != nil {
+		meta.CatchUp.Field = catchUpConfig.GetString("field")
+		meta.CatchUp.Threshold = defaultCatchUpThreshold
+
+		if catchUpConfig.IsSet("threshold") {
+			meta.CatchUp.Threshold = catchUpConfig.GetInt("threshold")
+		}
+
+		meta.CatchUp.Margin = defaultCatchUpMargin
+
+		if catchUpConfig.IsSet("margin") {
+			meta.CatchUp.Margin = catchUpConfig.GetDuration("margin")
+		}
+
+		if meta.CatchUp.Field == "" {
+			return meta, fmt.Errorf("invalid catch-up configuration: field is required")
+		}
+
+		if meta.CatchUp.Threshold < 0 {
+			return meta, fmt.Errorf("invalid catch-up configuration: threshold can't be negative")
+		}
+
+		if meta.CatchUp.Margin < 0 {
+			return meta, fmt.Errorf("invalid catch-up configuration: margin can't be negative")
+		}
+	}
+
+	healthConfig := viper.Sub("health")
+
+	if meta.Index != "" && healthConfig != nil {
+		return meta, fmt.Errorf("can't specify both static index and health configuration")
+	}
+
+	if healthConfig != nil {
+		if healthConfig.IsSet("status") {
+			meta.Health.Status = healthConfig.GetString("status")
+		}
+		if healthConfig.IsSet("timeout") {
+			meta.Health.Timeout = healthConfig.GetDuration("timeout")
+		}
+
+		if err = validateHealth(meta.Health); err != nil {
+			return meta, fmt.Errorf("invalid health configuration: %w", err)
+		}
 	}
 
 	return meta, nil
 }
 
+func validateRetention(retention IndexSetMetaRetention) error {
+	switch retention.Action {
+	case "", RetentionActionClose, RetentionActionReadOnly, RetentionActionDelete:
+	default:
+		return fmt.Errorf("unknown action %q - wanted %v, %v or %v", retention.Action, RetentionActionClose,
+			RetentionActionReadOnly, RetentionActionDelete)
+	}
+
+	if retention.Keep < 0 || retention.AfterDays < 0 {
+		return fmt.Errorf("keep and afterDays can't be negative")
+	}
+
+	return nil
+}
+
+func validateReindex(reindex IndexSetMetaReindex) error {
+	if reindex.RequestsPerSecond < -1 {
+		return fmt.Errorf("invalid requestsPerSecond %v - wanted a positive number, or -1 for unthrottled",
+			reindex.RequestsPerSecond)
+	}
+
+	if reindex.Slices != "" && reindex.Slices != "auto" {
+		if slices, err := strconv.Atoi(reindex.Slices); err != nil || slices < 1 {
+			return fmt.Errorf("invalid slices %q - wanted a positive number or auto", reindex.Slices)
+		}
+	}
+
+	if reindex.BatchSize < 0 {
+		return fmt.Errorf("batchSize can't be negative")
+	}
+
+	return nil
+}
+
+func validateHealth(health IndexSetMetaHealth) error {
+	switch health.Status {
+	case "", config.HealthGreen, config.HealthYellow:
+	default:
+		return fmt.Errorf("unknown status %q - wanted %v or %v", health.Status, config.HealthGreen,
+			config.HealthYellow)
+	}
+
+	if health.Status != "" && health.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	return nil
+}
+
 func getPipelines(config config.PipelinesConfig, envName string) ([]Pipeline, error) {
 	res, err := getEnvironmentResources(config.Directory, envName, "json")
 
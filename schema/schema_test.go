@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func Test_getSchema_resolvesResources(t *testing.T) {
@@ -84,6 +85,200 @@ reindex:
 			},
 			expectedErr: errors.New("can't specify both static index and reindexing configuration"),
 		},
+		{
+			desc:      "resolves retention from config",
+			envName:   "env1",
+			retention: config.RetentionConfig{Keep: 2, Action: "close", AfterDays: 7},
+			files: map[string]string{
+				"indexSets/x-env1.json": "",
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withRetention(IndexSetMetaRetention{Keep: 2, Action: "close", AfterDays: 7}),
+					),
+			},
+		},
+		{
+			desc:      "resolves retention from meta overriding config",
+			envName:   "env1",
+			retention: config.RetentionConfig{Keep: 2, Action: "close", AfterDays: 7},
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+retention:
+  action: delete
+  afterDays: 30`,
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withRetention(IndexSetMetaRetention{Keep: 2, Action: "delete", AfterDays: 30}),
+					),
+			},
+		},
+		{
+			desc:    "returns error if retention action unknown",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+retention:
+  action: archive`,
+			},
+			expectedErr: errors.New("invalid retention configuration: unknown action \"archive\" - " +
+				"wanted close, readOnly or delete"),
+		},
+		{
+			desc:    "resolves reindex tuning from meta overriding config",
+			envName: "env1",
+			reindex: config.ReindexConfig{RequestsPerSecond: 500, Slices: "auto"},
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+reindex:
+  pipeline: p1
+  slices: 4
+  batchSize: 100
+  blockWrites: true`,
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withReindex(IndexSetMetaReindex{Pipeline: "p1", RequestsPerSecond: 500, Slices: "4",
+								BatchSize: 100, BlockWrites: true}),
+					),
+			},
+		},
+		{
+			desc:    "returns error if reindex slices invalid",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+reindex:
+  slices: some`,
+			},
+			expectedErr: errors.New("invalid reindexing configuration: invalid slices \"some\" - " +
+				"wanted a positive number or auto"),
+		},
+		{
+			desc:    "resolves verification from meta",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+verify:
+  docCount: true
+  tolerance: 0.5`,
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withVerify(IndexSetMetaVerify{DocCount: true, Tolerance: 0.5}),
+					),
+			},
+		},
+		{
+			desc:    "resolves health from meta overriding config",
+			envName: "env1",
+			health:  config.HealthConfig{Status: "green", Timeout: time.Minute},
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+health:
+  status: yellow`,
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withHealth(IndexSetMetaHealth{Status: "yellow", Timeout: time.Minute}),
+					),
+			},
+		},
+		{
+			desc:    "returns error if health status unknown",
+			envName: "env1",
+			health:  config.HealthConfig{Timeout: time.Minute},
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+health:
+  status: red`,
+			},
+			expectedErr: errors.New("invalid health configuration: unknown status \"red\" - wanted green or yellow"),
+		},
+		{
+			desc:    "resolves catch-up from meta",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+catchUp:
+  field: updatedAt`,
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withMeta(
+						newIndexSetMetaMatcher().
+							withCatchUp(IndexSetMetaCatchUp{Field: "updatedAt", Threshold: 1000,
+								Margin: time.Minute}),
+					),
+			},
+		},
+		{
+			desc:    "returns error if blocking writes and catching up",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+reindex:
+  blockWrites: true
+catchUp:
+  field: updatedAt`,
+			},
+			expectedErr: errors.New("can't specify both blocking writes while reindexing and catch-up configuration"),
+		},
+		{
+			desc:    "returns error if catch-up field missing",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.meta.yml": `
+catchUp:
+  threshold: 10`,
+			},
+			expectedErr: errors.New("invalid catch-up configuration: field is required"),
+		},
+		{
+			desc:    "resolves checks for environment",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.json":                  "",
+				"indexSets/x-env1.checks/b-hits.json":    "",
+				"indexSets/x-env1.checks/a-ids.json":     "",
+				"indexSets/x-default.checks/c-aggs.json": "",
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withChecks("a-ids", "b-hits"),
+			},
+		},
+		{
+			desc:    "resolves checks from default",
+			envName: "env1",
+			files: map[string]string{
+				"indexSets/x-env1.json":                  "",
+				"indexSets/x-default.checks/c-aggs.json": "",
+			},
+			expected: []testutil.Matcher{
+				newIndexSetMatcher().
+					withName("x").
+					withChecks("c-aggs"),
+			},
+		},
 		{
 			desc:    "resolves resource from file and meta",
 			envName: "env1",
@@ -214,7 +409,12 @@ ignored: true`,
 			}
 
 			conf := config.Config{
-				IndexSets: config.IndexSetsConfig{Directory: path.Join(dir, "indexSets")},
+				IndexSets: config.IndexSetsConfig{
+					Directory: path.Join(dir, "indexSets"),
+					Retention: tc.retention,
+					Reindex:   tc.reindex,
+					Health:    tc.health,
+				},
 				Documents: config.DocumentsConfig{Directory: path.Join(dir, "documents")},
 			}
 
@@ -251,6 +451,9 @@ ignored: true`,
 type indexTestCase struct {
 	desc        string
 	envName     string
+	retention   config.RetentionConfig
+	reindex     config.ReindexConfig
+	health      config.HealthConfig
 	files       map[string]string
 	expected    []testutil.Matcher
 	expectedErr error
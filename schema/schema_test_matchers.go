@@ -14,6 +14,7 @@ type indexSetMatcher struct {
 	name         *string
 	filePathFile *string
 	meta         *indexSetMetaMatcher
+	checks       []string
 }
 
 func (m *indexSetMatcher) withName(name string) *indexSetMatcher {
@@ -31,6 +32,11 @@ func (m *indexSetMatcher) withMeta(meta *indexSetMetaMatcher) *indexSetMatcher {
 	return m
 }
 
+func (m *indexSetMatcher) withChecks(names ...string) *indexSetMatcher {
+	m.checks = names
+	return m
+}
+
 func (m *indexSetMatcher) withDefaultMeta() *indexSetMatcher {
 	m.meta = newIndexSetMetaMatcherLike(DefaultIndexSetMeta())
 	return m
@@ -60,6 +66,18 @@ func (m *indexSetMatcher) Match(actual interface{}) testutil.MatchResult {
 		}
 	}
 
+	if m.checks != nil {
+		got := make([]string, 0, len(is.Checks))
+
+		for _, c := range is.Checks {
+			got = append(got, c.Name)
+		}
+
+		if got, want := strings.Join(got, ","), strings.Join(m.checks, ","); got != want {
+			r.Reject(fmt.Sprintf("got checks %q, want %q", got, want))
+		}
+	}
+
 	if m.meta != nil {
 		if metaMatch := m.meta.Match(is.Meta); !metaMatch.Matched {
 			for _, f := range metaMatch.Failures {
@@ -79,13 +97,21 @@ func newIndexSetMetaMatcherLike(meta IndexSetMeta) *indexSetMetaMatcher {
 	return newIndexSetMetaMatcher().
 		withIndex(meta.Index).
 		withPrototype(meta.Prototype).
-		withReindex(meta.Reindex)
+		withReindex(meta.Reindex).
+		withRetention(meta.Retention).
+		withVerify(meta.Verify).
+		withHealth(meta.Health).
+		withCatchUp(meta.CatchUp)
 }
 
 type indexSetMetaMatcher struct {
 	index     *string
 	prototype *IndexSetMetaPrototype
 	reindex   *IndexSetMetaReindex
+	retention *IndexSetMetaRetention
+	verify    *IndexSetMetaVerify
+	health    *IndexSetMetaHealth
+	catchUp   *IndexSetMetaCatchUp
 }
 
 func (m *indexSetMetaMatcher) withIndex(index string) *indexSetMetaMatcher {
@@ -103,6 +129,26 @@ func (m *indexSetMetaMatcher) withReindex(reindex IndexSetMetaReindex) *indexSet
 	return m
 }
 
+func (m *indexSetMetaMatcher) withRetention(retention IndexSetMetaRetention) *indexSetMetaMatcher {
+	m.retention = &retention
+	return m
+}
+
+func (m *indexSetMetaMatcher) withVerify(verify IndexSetMetaVerify) *indexSetMetaMatcher {
+	m.verify = &verify
+	return m
+}
+
+func (m *indexSetMetaMatcher) withHealth(health IndexSetMetaHealth) *indexSetMetaMatcher {
+	m.health = &health
+	return m
+}
+
+func (m *indexSetMetaMatcher) withCatchUp(catchUp IndexSetMetaCatchUp) *indexSetMetaMatcher {
+	m.catchUp = &catchUp
+	return m
+}
+
 func (m *indexSetMetaMatcher) Match(actual interface{}) testutil.MatchResult {
 	r := testutil.NewMatchResult()
 
@@ -131,6 +177,30 @@ func (m *indexSetMetaMatcher) Match(actual interface{}) testutil.MatchResult {
 		}
 	}
 
+	if m.retention != nil {
+		if got, want := meta.Retention, *(m.retention); got != want {
+			r.Reject(fmt.Sprintf("got retention %v, want %v", got, want))
+		}
+	}
+
+	if m.verify != nil {
+		if got, want := meta.Verify, *(m.verify); got != want {
+			r.Reject(fmt.Sprintf("got verify %v, want %v", got, want))
+		}
+	}
+
+	if m.health != nil {
+		if got, want := meta.Health, *(m.health); got != want {
+			r.Reject(fmt.Sprintf("got health %v, want %v", got, want))
+		}
+	}
+
+	if m.catchUp != nil {
+		if got, want := meta.CatchUp, *(m.catchUp); got != want {
+			r.Reject(fmt.Sprintf("got catch-up %v, want %v", got, want))
+		}
+	}
+
 	return r
 }
 